@@ -312,6 +312,134 @@ func (x *HTTPFootRes) GetExtra() map[string]string {
 	return nil
 }
 
+// 批量上报，reqs中的每一项和单次上报相同
+type RPCFootBatchReq struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Reqs []*RPCFootReq `protobuf:"bytes,1,rep,name=reqs,proto3" json:"reqs,omitempty"`
+	// 上一次上报之后因为队列满丢弃的数量
+	Dropped int64             `protobuf:"varint,2,opt,name=dropped,proto3" json:"dropped,omitempty"`
+	Extra   map[string]string `protobuf:"bytes,3,rep,name=extra,proto3" json:"extra,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+}
+
+func (x *RPCFootBatchReq) Reset() {
+	*x = RPCFootBatchReq{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_footnode_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RPCFootBatchReq) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RPCFootBatchReq) ProtoMessage() {}
+
+func (x *RPCFootBatchReq) ProtoReflect() protoreflect.Message {
+	mi := &file_footnode_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RPCFootBatchReq.ProtoReflect.Descriptor instead.
+func (*RPCFootBatchReq) Descriptor() ([]byte, []int) {
+	return file_footnode_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *RPCFootBatchReq) GetReqs() []*RPCFootReq {
+	if x != nil {
+		return x.Reqs
+	}
+	return nil
+}
+
+func (x *RPCFootBatchReq) GetDropped() int64 {
+	if x != nil {
+		return x.Dropped
+	}
+	return 0
+}
+
+func (x *RPCFootBatchReq) GetExtra() map[string]string {
+	if x != nil {
+		return x.Extra
+	}
+	return nil
+}
+
+type RPCFootBatchRes struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Rescode int32             `protobuf:"varint,1,opt,name=rescode,proto3" json:"rescode,omitempty"`
+	Resmsg  string            `protobuf:"bytes,2,opt,name=resmsg,proto3" json:"resmsg,omitempty"`
+	Extra   map[string]string `protobuf:"bytes,3,rep,name=extra,proto3" json:"extra,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+}
+
+func (x *RPCFootBatchRes) Reset() {
+	*x = RPCFootBatchRes{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_footnode_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RPCFootBatchRes) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RPCFootBatchRes) ProtoMessage() {}
+
+func (x *RPCFootBatchRes) ProtoReflect() protoreflect.Message {
+	mi := &file_footnode_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RPCFootBatchRes.ProtoReflect.Descriptor instead.
+func (*RPCFootBatchRes) Descriptor() ([]byte, []int) {
+	return file_footnode_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *RPCFootBatchRes) GetRescode() int32 {
+	if x != nil {
+		return x.Rescode
+	}
+	return 0
+}
+
+func (x *RPCFootBatchRes) GetResmsg() string {
+	if x != nil {
+		return x.Resmsg
+	}
+	return ""
+}
+
+func (x *RPCFootBatchRes) GetExtra() map[string]string {
+	if x != nil {
+		return x.Extra
+	}
+	return nil
+}
+
 var File_footnode_proto protoreflect.FileDescriptor
 
 var file_footnode_proto_rawDesc = []byte{
@@ -368,9 +496,33 @@ var file_footnode_proto_rawDesc = []byte{
 	0x78, 0x74, 0x72, 0x61, 0x1a, 0x38, 0x0a, 0x0a, 0x45, 0x78, 0x74, 0x72, 0x61, 0x45, 0x6e, 0x74,
 	0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52,
 	0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20,
-	0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x42, 0x0c,
-	0x5a, 0x0a, 0x2e, 0x2f, 0x63, 0x61, 0x6c, 0x6c, 0x66, 0x6f, 0x6f, 0x74, 0x62, 0x06, 0x70, 0x72,
-	0x6f, 0x74, 0x6f, 0x33,
+	0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0xcb,
+	0x01, 0x0a, 0x0f, 0x52, 0x50, 0x43, 0x46, 0x6f, 0x6f, 0x74, 0x42, 0x61, 0x74, 0x63, 0x68, 0x52,
+	0x65, 0x71, 0x12, 0x28, 0x0a, 0x04, 0x72, 0x65, 0x71, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b,
+	0x32, 0x14, 0x2e, 0x63, 0x61, 0x6c, 0x6c, 0x66, 0x6f, 0x6f, 0x74, 0x2e, 0x52, 0x50, 0x43, 0x46,
+	0x6f, 0x6f, 0x74, 0x52, 0x65, 0x71, 0x52, 0x04, 0x72, 0x65, 0x71, 0x73, 0x12, 0x18, 0x0a, 0x07,
+	0x64, 0x72, 0x6f, 0x70, 0x70, 0x65, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x07, 0x64,
+	0x72, 0x6f, 0x70, 0x70, 0x65, 0x64, 0x12, 0x3a, 0x0a, 0x05, 0x65, 0x78, 0x74, 0x72, 0x61, 0x18,
+	0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x24, 0x2e, 0x63, 0x61, 0x6c, 0x6c, 0x66, 0x6f, 0x6f, 0x74,
+	0x2e, 0x52, 0x50, 0x43, 0x46, 0x6f, 0x6f, 0x74, 0x42, 0x61, 0x74, 0x63, 0x68, 0x52, 0x65, 0x71,
+	0x2e, 0x45, 0x78, 0x74, 0x72, 0x61, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x05, 0x65, 0x78, 0x74,
+	0x72, 0x61, 0x1a, 0x38, 0x0a, 0x0a, 0x45, 0x78, 0x74, 0x72, 0x61, 0x45, 0x6e, 0x74, 0x72, 0x79,
+	0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b,
+	0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x22, 0xb9, 0x01, 0x0a,
+	0x0f, 0x52, 0x50, 0x43, 0x46, 0x6f, 0x6f, 0x74, 0x42, 0x61, 0x74, 0x63, 0x68, 0x52, 0x65, 0x73,
+	0x12, 0x18, 0x0a, 0x07, 0x72, 0x65, 0x73, 0x63, 0x6f, 0x64, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x05, 0x52, 0x07, 0x72, 0x65, 0x73, 0x63, 0x6f, 0x64, 0x65, 0x12, 0x16, 0x0a, 0x06, 0x72, 0x65,
+	0x73, 0x6d, 0x73, 0x67, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x72, 0x65, 0x73, 0x6d,
+	0x73, 0x67, 0x12, 0x3a, 0x0a, 0x05, 0x65, 0x78, 0x74, 0x72, 0x61, 0x18, 0x03, 0x20, 0x03, 0x28,
+	0x0b, 0x32, 0x24, 0x2e, 0x63, 0x61, 0x6c, 0x6c, 0x66, 0x6f, 0x6f, 0x74, 0x2e, 0x52, 0x50, 0x43,
+	0x46, 0x6f, 0x6f, 0x74, 0x42, 0x61, 0x74, 0x63, 0x68, 0x52, 0x65, 0x73, 0x2e, 0x45, 0x78, 0x74,
+	0x72, 0x61, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x05, 0x65, 0x78, 0x74, 0x72, 0x61, 0x1a, 0x38,
+	0x0a, 0x0a, 0x45, 0x78, 0x74, 0x72, 0x61, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03,
+	0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14,
+	0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76,
+	0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38, 0x01, 0x42, 0x0c, 0x5a, 0x0a, 0x2e, 0x2f, 0x63, 0x61,
+	0x6c, 0x6c, 0x66, 0x6f, 0x6f, 0x74, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
 }
 
 var (
@@ -385,27 +537,34 @@ func file_footnode_proto_rawDescGZIP() []byte {
 	return file_footnode_proto_rawDescData
 }
 
-var file_footnode_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
+var file_footnode_proto_msgTypes = make([]protoimpl.MessageInfo, 12)
 var file_footnode_proto_goTypes = []interface{}{
-	(*RPCFootReq)(nil),  // 0: callfoot.RPCFootReq
-	(*RPCFootRes)(nil),  // 1: callfoot.RPCFootRes
-	(*HTTPFootReq)(nil), // 2: callfoot.HTTPFootReq
-	(*HTTPFootRes)(nil), // 3: callfoot.HTTPFootRes
-	nil,                 // 4: callfoot.RPCFootReq.ExtraEntry
-	nil,                 // 5: callfoot.RPCFootRes.ExtraEntry
-	nil,                 // 6: callfoot.HTTPFootReq.ExtraEntry
-	nil,                 // 7: callfoot.HTTPFootRes.ExtraEntry
+	(*RPCFootReq)(nil),      // 0: callfoot.RPCFootReq
+	(*RPCFootRes)(nil),      // 1: callfoot.RPCFootRes
+	(*HTTPFootReq)(nil),     // 2: callfoot.HTTPFootReq
+	(*HTTPFootRes)(nil),     // 3: callfoot.HTTPFootRes
+	(*RPCFootBatchReq)(nil), // 4: callfoot.RPCFootBatchReq
+	(*RPCFootBatchRes)(nil), // 5: callfoot.RPCFootBatchRes
+	nil,                     // 6: callfoot.RPCFootReq.ExtraEntry
+	nil,                     // 7: callfoot.RPCFootRes.ExtraEntry
+	nil,                     // 8: callfoot.HTTPFootReq.ExtraEntry
+	nil,                     // 9: callfoot.HTTPFootRes.ExtraEntry
+	nil,                     // 10: callfoot.RPCFootBatchReq.ExtraEntry
+	nil,                     // 11: callfoot.RPCFootBatchRes.ExtraEntry
 }
 var file_footnode_proto_depIdxs = []int32{
-	4, // 0: callfoot.RPCFootReq.extra:type_name -> callfoot.RPCFootReq.ExtraEntry
-	5, // 1: callfoot.RPCFootRes.extra:type_name -> callfoot.RPCFootRes.ExtraEntry
-	6, // 2: callfoot.HTTPFootReq.extra:type_name -> callfoot.HTTPFootReq.ExtraEntry
-	7, // 3: callfoot.HTTPFootRes.extra:type_name -> callfoot.HTTPFootRes.ExtraEntry
-	4, // [4:4] is the sub-list for method output_type
-	4, // [4:4] is the sub-list for method input_type
-	4, // [4:4] is the sub-list for extension type_name
-	4, // [4:4] is the sub-list for extension extendee
-	0, // [0:4] is the sub-list for field type_name
+	6,  // 0: callfoot.RPCFootReq.extra:type_name -> callfoot.RPCFootReq.ExtraEntry
+	7,  // 1: callfoot.RPCFootRes.extra:type_name -> callfoot.RPCFootRes.ExtraEntry
+	8,  // 2: callfoot.HTTPFootReq.extra:type_name -> callfoot.HTTPFootReq.ExtraEntry
+	9,  // 3: callfoot.HTTPFootRes.extra:type_name -> callfoot.HTTPFootRes.ExtraEntry
+	0,  // 4: callfoot.RPCFootBatchReq.reqs:type_name -> callfoot.RPCFootReq
+	10, // 5: callfoot.RPCFootBatchReq.extra:type_name -> callfoot.RPCFootBatchReq.ExtraEntry
+	11, // 6: callfoot.RPCFootBatchRes.extra:type_name -> callfoot.RPCFootBatchRes.ExtraEntry
+	7,  // [7:7] is the sub-list for method output_type
+	7,  // [7:7] is the sub-list for method input_type
+	7,  // [7:7] is the sub-list for extension type_name
+	7,  // [7:7] is the sub-list for extension extendee
+	0,  // [0:7] is the sub-list for field type_name
 }
 
 func init() { file_footnode_proto_init() }
@@ -462,6 +621,30 @@ func file_footnode_proto_init() {
 				return nil
 			}
 		}
+		file_footnode_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RPCFootBatchReq); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_footnode_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RPCFootBatchRes); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
@@ -469,7 +652,7 @@ func file_footnode_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_footnode_proto_rawDesc,
 			NumEnums:      0,
-			NumMessages:   8,
+			NumMessages:   12,
 			NumExtensions: 0,
 			NumServices:   0,
 		},
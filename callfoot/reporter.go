@@ -0,0 +1,218 @@
+package callfoot
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	DefaultQueueSize     = 10000
+	DefaultBatchSize     = 100
+	DefaultFlushInterval = time.Second
+)
+
+// 发送一批调用数据，在后台协程中调用
+type SendFunc func(batch *RPCFootBatchReq) error
+
+type ReporterOptions struct {
+	// 队列长度，队列满时丢弃新的数据
+	QueueSize int
+	// 每批最多发送的数量，达到后立即发送
+	BatchSize int
+	// 不足一批时的发送间隔
+	FlushInterval time.Duration
+}
+
+// 上报的统计
+type ReporterStats struct {
+	// 当前队列中等待发送的数量
+	Queued int64
+	// 队列满或者已经关闭而丢弃的数量
+	Dropped int64
+	// 发送成功的数量
+	Sent int64
+	// 发送失败的数量
+	Failed int64
+}
+
+// 异步批量上报调用数据，Report不会阻塞调用方
+type Reporter struct {
+	opts  ReporterOptions
+	send  SendFunc
+	queue chan *RPCFootReq
+
+	dropped int64
+	sent    int64
+	failed  int64
+	// 上一次发送之后丢弃的数量，随下一批发送
+	unreported int64
+
+	closed int32
+	exit   chan bool
+	done   chan bool
+	once   sync.Once
+}
+
+// 创建上报对象并开始后台发送
+//
+// @param send
+// @param opts 	小于等于0的选项使用默认值
+// @return *Reporter
+//
+func NewReporter(send SendFunc, opts ReporterOptions) *Reporter {
+	if 0 >= opts.QueueSize {
+		opts.QueueSize = DefaultQueueSize
+	}
+	if 0 >= opts.BatchSize {
+		opts.BatchSize = DefaultBatchSize
+	}
+	if 0 >= opts.FlushInterval {
+		opts.FlushInterval = DefaultFlushInterval
+	}
+
+	r := &Reporter{
+		opts:  opts,
+		send:  send,
+		queue: make(chan *RPCFootReq, opts.QueueSize),
+		exit:  make(chan bool),
+		done:  make(chan bool),
+	}
+
+	go r.run()
+	return r
+}
+
+// 加入发送队列，队列满或者已经关闭时丢弃
+//
+// @param req
+// @return bool 	是否加入了队列
+//
+func (r *Reporter) Report(req *RPCFootReq) bool {
+	if 1 == atomic.LoadInt32(&r.closed) {
+		r.drop()
+
+		return false
+	}
+
+	select {
+	case r.queue <- req:
+		return true
+	default:
+		r.drop()
+
+		return false
+	}
+}
+
+func (r *Reporter) drop() {
+	atomic.AddInt64(&r.dropped, 1)
+	atomic.AddInt64(&r.unreported, 1)
+}
+
+// 当前的统计
+//
+// @return ReporterStats
+//
+func (r *Reporter) Stats() ReporterStats {
+	return ReporterStats{
+		Queued:  int64(len(r.queue)),
+		Dropped: atomic.LoadInt64(&r.dropped),
+		Sent:    atomic.LoadInt64(&r.sent),
+		Failed:  atomic.LoadInt64(&r.failed),
+	}
+}
+
+// 停止接收新的数据，发送队列中剩余的数据
+//
+// @param ctx 	超时后不再等待发送完成
+// @return error
+//
+func (r *Reporter) Close(ctx context.Context) error {
+	r.once.Do(func() {
+		atomic.StoreInt32(&r.closed, 1)
+		close(r.exit)
+	})
+
+	select {
+	case <-r.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *Reporter) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.opts.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*RPCFootReq, 0, r.opts.BatchSize)
+	for {
+		select {
+		case req := <-r.queue:
+			batch = append(batch, req)
+			if len(batch) < r.opts.BatchSize {
+				continue
+			}
+
+		case <-ticker.C:
+			if 0 == len(batch) && 0 == atomic.LoadInt64(&r.unreported) {
+				continue
+			}
+
+		case <-r.exit:
+			// 关闭后Report不再加入队列，取出剩余的数据
+			for {
+				select {
+				case req := <-r.queue:
+					batch = append(batch, req)
+					if len(batch) >= r.opts.BatchSize {
+						r.flush(batch)
+						batch = batch[:0]
+					}
+
+					continue
+				default:
+				}
+
+				break
+			}
+
+			r.flush(batch)
+			return
+		}
+
+		r.flush(batch)
+		batch = batch[:0]
+	}
+}
+
+// 发送一批数据，失败时不重试，丢弃的数量随下一批发送
+//
+// @param batch
+//
+func (r *Reporter) flush(batch []*RPCFootReq) {
+	dropped := atomic.SwapInt64(&r.unreported, 0)
+	if 0 == len(batch) && 0 == dropped {
+		return
+	}
+
+	req := &RPCFootBatchReq{
+		Reqs:    make([]*RPCFootReq, len(batch)),
+		Dropped: dropped,
+	}
+	copy(req.Reqs, batch)
+
+	err := r.send(req)
+	if nil != err {
+		atomic.AddInt64(&r.failed, int64(len(batch)))
+		atomic.AddInt64(&r.unreported, dropped)
+
+		return
+	}
+
+	atomic.AddInt64(&r.sent, int64(len(batch)))
+}
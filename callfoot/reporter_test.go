@@ -0,0 +1,71 @@
+package callfoot
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_Reporter(t *testing.T) {
+	var lock sync.Mutex
+	batches := make([]*RPCFootBatchReq, 0)
+	block := make(chan bool)
+
+	r := NewReporter(func(batch *RPCFootBatchReq) error {
+		<-block
+
+		lock.Lock()
+		batches = append(batches, batch)
+		lock.Unlock()
+		return nil
+	}, ReporterOptions{QueueSize: 4, BatchSize: 2, FlushInterval: time.Hour})
+
+	// 第一批阻塞在发送中，队列满后丢弃
+	for i := 0; i < 10; i++ {
+		r.Report(&RPCFootReq{Method: "m"})
+	}
+	time.Sleep(10 * time.Millisecond)
+	if stats := r.Stats(); 0 == stats.Dropped || 4 < stats.Queued {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	dropped := r.Stats().Dropped
+
+	close(block)
+	if err := r.Close(context.Background()); nil != err {
+		t.Fatal(err)
+	}
+	if r.Report(&RPCFootReq{}) {
+		t.Fatal("report after close")
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	total, reported := 0, int64(0)
+	for _, b := range batches {
+		total += len(b.Reqs)
+		reported += b.Dropped
+	}
+	if stats := r.Stats(); int64(total) != stats.Sent || 10 != stats.Sent+dropped || dropped != reported {
+		t.Fatalf("unexpected batches %d %d %+v", total, reported, stats)
+	}
+}
+
+func Test_ReporterFailed(t *testing.T) {
+	r := NewReporter(func(batch *RPCFootBatchReq) error {
+		return errors.New("footnode unavailable")
+	}, ReporterOptions{FlushInterval: 10 * time.Millisecond})
+
+	r.Report(&RPCFootReq{})
+	r.Report(&RPCFootReq{})
+	time.Sleep(50 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	r.Close(ctx)
+
+	if stats := r.Stats(); 2 != stats.Failed || 0 != stats.Sent {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
@@ -0,0 +1,80 @@
+// s2sd is a local stand-in for the production s2s registry server.
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	s2s "github.com/heegspace/heegrpc/registry"
+	"github.com/heegspace/heegrpc/registry/s2sd"
+	"go-micro.dev/v4/logger"
+)
+
+func main() {
+	httpAddr := flag.String("http", "127.0.0.1:8081", "http listen address, empty to disable")
+	tcpAddr := flag.String("tcp", "127.0.0.1:8082", "tcp listen address, empty to disable")
+	ttl := flag.Duration("ttl", 90*time.Second, "default node ttl")
+	cert := flag.String("cert", "", "tls certificate file, empty to disable tls")
+	key := flag.String("key", "", "tls private key file")
+	ca := flag.String("ca", "", "ca file to verify client certificates, empty to skip client auth")
+	signKey := flag.String("sign-key", "", "shared key to verify signed registrations, empty to disable")
+	flag.Parse()
+
+	opts := []s2sd.Option{
+		s2sd.HttpAddr(*httpAddr),
+		s2sd.TcpAddr(*tcpAddr),
+		s2sd.TTL(*ttl),
+	}
+	if 0 < len(*cert) {
+		config, err := tlsConfig(*cert, *key, *ca)
+		if nil != err {
+			logger.Fatal("s2sd tls config err ", err)
+		}
+
+		opts = append(opts, s2sd.TLSConfig(config))
+	}
+
+	if 0 < len(*signKey) {
+		opts = append(opts, s2sd.Verifier(s2s.NewSharedVerifier([]byte(*signKey), 0)))
+	}
+
+	svr := s2sd.New(opts...)
+	if err := svr.Start(); nil != err {
+		logger.Fatal("s2sd start err ", err)
+	}
+
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM)
+	<-ch
+
+	svr.Stop()
+}
+
+func tlsConfig(cert, key, ca string) (*tls.Config, error) {
+	pair, err := tls.LoadX509KeyPair(cert, key)
+	if nil != err {
+		return nil, err
+	}
+
+	config := &tls.Config{Certificates: []tls.Certificate{pair}}
+	if 0 == len(ca) {
+		return config, nil
+	}
+
+	data, err := ioutil.ReadFile(ca)
+	if nil != err {
+		return nil, err
+	}
+
+	config.ClientCAs = x509.NewCertPool()
+	config.ClientCAs.AppendCertsFromPEM(data)
+	config.ClientAuth = tls.RequireAndVerifyClientCert
+
+	return config, nil
+}
@@ -0,0 +1,81 @@
+package conf
+
+import (
+	"math"
+
+	"github.com/heegspace/heegapo"
+)
+
+// apollo中一个namespace的配置，使用heegapo.DefaultApollo
+type apollo struct {
+	namespace string
+}
+
+// 创建apollo配置来源
+//
+// @param namespace 	apollo的namespace，例如heegspace.common.yaml
+// @return Provider
+//
+func NewApollo(namespace string) Provider {
+	return &apollo{
+		namespace: namespace,
+	}
+}
+
+func (a *apollo) Get(keys ...string) Value {
+	return apolloValue{
+		namespace: a.namespace,
+		keys:      keys,
+	}
+}
+
+func (a *apollo) String() string {
+	return "apollo:" + a.namespace
+}
+
+// 每次读取都从heegapo中获取，apollo推送的修改可以立即生效
+type apolloValue struct {
+	namespace string
+	keys      []string
+}
+
+// 用于判断配置是否存在的默认值
+const missing = "\x00heegconf-missing"
+
+// heegapo没有提供是否存在的接口，使用不会出现的默认值判断
+func (v apolloValue) Exists() bool {
+	val := heegapo.DefaultApollo.Config(v.namespace, v.keys...)
+	if missing != val.String(missing) {
+		return true
+	}
+
+	return math.MinInt64 != val.Int64(math.MinInt64) || val.Bool()
+}
+
+func (v apolloValue) String(def string) string {
+	return heegapo.DefaultApollo.Config(v.namespace, v.keys...).String(def)
+}
+
+func (v apolloValue) Int(def int) int {
+	return heegapo.DefaultApollo.Config(v.namespace, v.keys...).Int(def)
+}
+
+func (v apolloValue) Int64(def int64) int64 {
+	return heegapo.DefaultApollo.Config(v.namespace, v.keys...).Int64(def)
+}
+
+func (v apolloValue) Float64(def float64) float64 {
+	return heegapo.DefaultApollo.Config(v.namespace, v.keys...).Float64(def)
+}
+
+func (v apolloValue) Bool(def bool) bool {
+	if !v.Exists() {
+		return def
+	}
+
+	return heegapo.DefaultApollo.Config(v.namespace, v.keys...).Bool()
+}
+
+func (v apolloValue) StringSlice(def []string) []string {
+	return heegapo.DefaultApollo.Config(v.namespace, v.keys...).StringSlice(def)
+}
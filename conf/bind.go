@@ -0,0 +1,275 @@
+package conf
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go-micro.dev/v4/logger"
+	"go.uber.org/zap"
+)
+
+// 配置变化的回调，old和new是绑定结构的副本，类型和绑定时传入的指针相同
+// 回调中不能修改old和new
+type ChangeFunc func(old, new interface{})
+
+// 把配置来源中的配置解析到结构中
+// 字段的路径使用yaml tag中的名字，没有tag时使用小写的字段名，tag为-时跳过
+// 支持字符串、数字、bool、[]string和嵌套的结构，配置中没有的字段为零值
+//
+// @param p
+// @param out 	结构指针
+// @return error
+//
+func Bind(p Provider, out interface{}) error {
+	rv := reflect.ValueOf(out)
+	if reflect.Ptr != rv.Kind() || rv.IsNil() || reflect.Struct != rv.Elem().Kind() {
+		return errors.New("conf must be a non-nil struct pointer")
+	}
+
+	return bindStruct(p, nil, rv.Elem())
+}
+
+// 按字段的路径读取配置
+//
+// @param p
+// @param prefix 	结构在配置中的路径
+// @param rv
+// @return error
+//
+func bindStruct(p Provider, prefix []string, rv reflect.Value) error {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if 0 < len(field.PkgPath) {
+			continue
+		}
+
+		name, inline := fieldName(field)
+		if "-" == name {
+			continue
+		}
+
+		keys := prefix
+		if !inline {
+			keys = make([]string, len(prefix), len(prefix)+1)
+			copy(keys, prefix)
+			keys = append(keys, name)
+		}
+
+		err := bindField(p, keys, rv.Field(i))
+		if nil != err {
+			return fmt.Errorf("%s: %v", strings.Join(keys, "."), err)
+		}
+	}
+
+	return nil
+}
+
+// 字段在配置中的名字
+//
+// @param field
+// @return {name,inline}
+//
+func fieldName(field reflect.StructField) (string, bool) {
+	tag := field.Tag.Get("yaml")
+	if "-" == tag {
+		return tag, false
+	}
+
+	parts := strings.Split(tag, ",")
+	for _, opt := range parts[1:] {
+		if "inline" == opt {
+			return "", true
+		}
+	}
+	if 0 < len(parts[0]) {
+		return parts[0], false
+	}
+
+	return strings.ToLower(field.Name), false
+}
+
+// 读取一个字段
+//
+// @param p
+// @param keys
+// @param fv
+// @return error
+//
+func bindField(p Provider, keys []string, fv reflect.Value) error {
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(p.Get(keys...).String(""))
+
+	case reflect.Bool:
+		fv.SetBool(p.Get(keys...).Bool(false))
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		fv.SetInt(p.Get(keys...).Int64(0))
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		fv.SetUint(uint64(p.Get(keys...).Int64(0)))
+
+	case reflect.Float32, reflect.Float64:
+		fv.SetFloat(p.Get(keys...).Float64(0))
+
+	case reflect.Slice:
+		if reflect.String != fv.Type().Elem().Kind() {
+			return errors.New("unsupported type " + fv.Type().String())
+		}
+
+		// 复制一份，内存中的配置修改后不会影响已经绑定的结构
+		ss := p.Get(keys...).StringSlice(nil)
+		if nil == ss {
+			fv.Set(reflect.Zero(fv.Type()))
+
+			break
+		}
+		sv := reflect.MakeSlice(fv.Type(), len(ss), len(ss))
+		for i, s := range ss {
+			sv.Index(i).SetString(s)
+		}
+		fv.Set(sv)
+
+	case reflect.Struct:
+		return bindStruct(p, keys, fv)
+
+	default:
+		return errors.New("unsupported type " + fv.Type().String())
+	}
+
+	return nil
+}
+
+// 绑定到结构的配置，定时重新读取，变化后发布新的副本
+// 绑定之后不会再修改传入的结构，最新的配置通过Load获取
+type Binding struct {
+	p    Provider
+	typ  reflect.Type
+	name string
+
+	// 当前配置，类型和绑定时传入的指针相同
+	value atomic.Value
+
+	lock      sync.Mutex
+	callbacks []ChangeFunc
+
+	exit chan bool
+	once sync.Once
+}
+
+// 把配置解析到结构中，并且每interval检查一次配置的变化
+//
+// @param p
+// @param out 		结构指针，只在绑定时填充
+// @param interval 	小于等于0时不检查变化
+// @return {*Binding,error}
+//
+func NewBinding(p Provider, out interface{}, interval time.Duration) (*Binding, error) {
+	err := Bind(p, out)
+	if nil != err {
+		return nil, err
+	}
+
+	b := &Binding{
+		p:    p,
+		typ:  reflect.TypeOf(out).Elem(),
+		name: p.String(),
+		exit: make(chan bool),
+	}
+
+	// 保存一份副本，调用方修改out不会影响发布的配置
+	cur := reflect.New(b.typ)
+	cur.Elem().Set(reflect.ValueOf(out).Elem())
+	b.value.Store(cur.Interface())
+
+	if 0 < interval {
+		go b.watch(interval)
+	}
+
+	return b, nil
+}
+
+// 最新的配置，类型和绑定时传入的指针相同，不能修改
+//
+// @return interface{}
+//
+func (b *Binding) Load() interface{} {
+	return b.value.Load()
+}
+
+// 注册配置变化的回调，在发布新的配置之后调用
+//
+// @param fn
+//
+func (b *Binding) OnChange(fn ChangeFunc) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.callbacks = append(b.callbacks, fn)
+}
+
+// 停止检查配置的变化
+//
+func (b *Binding) Stop() {
+	b.once.Do(func() {
+		close(b.exit)
+	})
+}
+
+// 定时检查配置的变化
+//
+// @param interval
+//
+func (b *Binding) watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			err := b.refresh()
+			if nil != err {
+				logger.Error("rebind conf err", zap.Any("provider", b.name), zap.Error(err))
+			}
+
+		case <-b.exit:
+			return
+		}
+	}
+}
+
+// 重新读取配置，有变化时发布新的副本并调用回调，读取失败时保留原来的配置
+//
+// @return error
+//
+func (b *Binding) refresh() error {
+	next := reflect.New(b.typ)
+	err := Bind(b.p, next.Interface())
+	if nil != err {
+		return err
+	}
+
+	old := b.value.Load()
+	if reflect.DeepEqual(old, next.Interface()) {
+		return nil
+	}
+	b.value.Store(next.Interface())
+
+	b.lock.Lock()
+	callbacks := make([]ChangeFunc, len(b.callbacks))
+	copy(callbacks, b.callbacks)
+	b.lock.Unlock()
+
+	logger.Info("conf changed", zap.Any("provider", b.name))
+	for _, fn := range callbacks {
+		fn(old, next.Interface())
+	}
+
+	return nil
+}
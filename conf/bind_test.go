@@ -0,0 +1,212 @@
+package conf
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type bindConf struct {
+	Name    string
+	Limit   int `yaml:"limit"`
+	Rate    float64
+	Enable  bool
+	Nodes   []string
+	Ignored string `yaml:"-"`
+	S2s     struct {
+		Zone string `yaml:"zone"`
+		Port uint16 `yaml:"tcp_port"`
+	} `yaml:"s2s"`
+}
+
+func Test_Bind(t *testing.T) {
+	m := NewMemory(map[string]interface{}{
+		"name":    "a.svr",
+		"limit":   "10",
+		"rate":    1.5,
+		"enable":  true,
+		"nodes":   []interface{}{"b.svr", "c.svr"},
+		"ignored": "x",
+		"s2s": map[string]interface{}{
+			"zone":     "sh",
+			"tcp_port": 9001,
+		},
+	})
+
+	var c bindConf
+	err := Bind(m, &c)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if "a.svr" != c.Name || 10 != c.Limit || 1.5 != c.Rate || !c.Enable || 0 < len(c.Ignored) {
+		t.Fatalf("unexpected conf %+v", c)
+	}
+	if !reflect.DeepEqual([]string{"b.svr", "c.svr"}, c.Nodes) || "sh" != c.S2s.Zone || 9001 != c.S2s.Port {
+		t.Fatalf("unexpected conf %+v", c)
+	}
+
+	if nil == Bind(m, c) {
+		t.Fatal("bind to a non-pointer")
+	}
+	var bad struct {
+		Limits map[string]int
+	}
+	if nil == Bind(m, &bad) {
+		t.Fatal("bind to an unsupported type")
+	}
+}
+
+func Test_Binding(t *testing.T) {
+	m := NewMemory(nil)
+	m.Set(10, "limit")
+
+	var c bindConf
+	b, err := NewBinding(m, &c, 10*time.Millisecond)
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer b.Stop()
+
+	changed := make(chan [2]*bindConf, 1)
+	b.OnChange(func(old, new interface{}) {
+		changed <- [2]*bindConf{old.(*bindConf), new.(*bindConf)}
+	})
+
+	// 修改传入的结构不影响发布的配置
+	c.Limit = 1
+	if 10 != b.Load().(*bindConf).Limit {
+		t.Fatalf("unexpected limit %d", b.Load().(*bindConf).Limit)
+	}
+
+	m.Set(20, "limit")
+	select {
+	case v := <-changed:
+		if 10 != v[0].Limit || 20 != v[1].Limit {
+			t.Fatalf("unexpected change %d -> %d", v[0].Limit, v[1].Limit)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("change not notified")
+	}
+	if 20 != b.Load().(*bindConf).Limit || 1 != c.Limit {
+		t.Fatalf("unexpected limit %d %d", b.Load().(*bindConf).Limit, c.Limit)
+	}
+
+	// 没有变化时不调用回调
+	select {
+	case <-changed:
+		t.Fatal("unexpected change")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// 进程内的apollo配置服务，只实现properties格式的configs接口
+type fakeApollo struct {
+	lock    sync.Mutex
+	configs map[string]map[string]string
+}
+
+func (f *fakeApollo) set(namespace, key, value string) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if nil == f.configs[namespace] {
+		f.configs[namespace] = make(map[string]string)
+	}
+	f.configs[namespace][key] = value
+}
+
+// /configs/{appId}/{cluster}/{namespace}
+func (f *fakeApollo) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if 4 != len(parts) || "configs" != parts[0] {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	f.lock.Lock()
+	configurations, ok := f.configs[parts[3]]
+	data, _ := json.Marshal(map[string]interface{}{
+		"appId":          parts[1],
+		"cluster":        parts[2],
+		"namespaceName":  parts[3],
+		"configurations": configurations,
+	})
+	f.lock.Unlock()
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	w.Write(data)
+}
+
+// 从模拟的apollo读取配置，properties的key使用.连接路径
+type fakeApolloProvider struct {
+	url       string
+	namespace string
+}
+
+func (p *fakeApolloProvider) Get(keys ...string) Value {
+	res, err := http.Get(p.url + "/configs/test.svr/default/" + p.namespace)
+	if nil != err {
+		return rawValue{}
+	}
+	defer res.Body.Close()
+
+	var result struct {
+		Configurations map[string]string `json:"configurations"`
+	}
+	if nil != json.NewDecoder(res.Body).Decode(&result) {
+		return rawValue{}
+	}
+
+	v, ok := result.Configurations[strings.Join(keys, ".")]
+	return rawValue{v: v, ok: ok}
+}
+
+func (p *fakeApolloProvider) String() string {
+	return "fake-apollo:" + p.namespace
+}
+
+func Test_BindingApollo(t *testing.T) {
+	fake := &fakeApollo{configs: make(map[string]map[string]string)}
+	fake.set("app", "limit", "10")
+	fake.set("app", "s2s.zone", "sh")
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	var c bindConf
+	b, err := NewBinding(&fakeApolloProvider{url: server.URL, namespace: "app"}, &c, 10*time.Millisecond)
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer b.Stop()
+	if 10 != c.Limit || "sh" != c.S2s.Zone {
+		t.Fatalf("unexpected conf %+v", c)
+	}
+
+	changed := make(chan [2]*bindConf, 1)
+	b.OnChange(func(old, new interface{}) {
+		changed <- [2]*bindConf{old.(*bindConf), new.(*bindConf)}
+	})
+
+	fake.set("app", "s2s.zone", "bj")
+	select {
+	case v := <-changed:
+		if "sh" != v[0].S2s.Zone || "bj" != v[1].S2s.Zone || 10 != v[1].Limit {
+			t.Fatalf("unexpected change %+v -> %+v", v[0], v[1])
+		}
+	case <-time.After(time.Second):
+		t.Fatal("change not notified")
+	}
+
+	// 发布的配置已经更新，绑定时传入的结构是加载时的快照
+	if "bj" != b.Load().(*bindConf).S2s.Zone || "sh" != c.S2s.Zone {
+		t.Fatalf("unexpected zone %s %s", b.Load().(*bindConf).S2s.Zone, c.S2s.Zone)
+	}
+}
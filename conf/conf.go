@@ -0,0 +1,243 @@
+package conf
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 配置中的一个值，不存在或者类型不能转换时返回默认值
+type Value interface {
+	Exists() bool
+	String(def string) string
+	Int(def int) int
+	Int64(def int64) int64
+	Float64(def float64) float64
+	Bool(def bool) bool
+	StringSlice(def []string) []string
+}
+
+// 配置来源，keys为配置的路径，例如Get("s2s", "zone")
+type Provider interface {
+	Get(keys ...string) Value
+	String() string
+}
+
+// apollo中公共配置的namespace
+const CommonNamespace = "heegspace.common.yaml"
+
+var (
+	defaultProvider Provider
+	lock            sync.RWMutex
+)
+
+// 设置默认的配置来源，service和registry没有指定配置时使用
+//
+// @param p 	为nil时恢复为环境变量和apollo
+//
+func SetDefault(p Provider) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	defaultProvider = p
+}
+
+// 默认的配置来源，没有设置时环境变量HEEG_*优先，其次是apollo中的公共配置
+//
+// @return Provider
+//
+func Default() Provider {
+	lock.RLock()
+	p := defaultProvider
+	lock.RUnlock()
+	if nil != p {
+		return p
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	if nil == defaultProvider {
+		defaultProvider = NewLayered(NewEnv("HEEG"), NewApollo(CommonNamespace))
+	}
+
+	return defaultProvider
+}
+
+// 从默认的配置来源中读取配置
+//
+// @param keys
+// @return Value
+//
+func Get(keys ...string) Value {
+	return Default().Get(keys...)
+}
+
+// 按路径查找map中的值，支持yaml解析出的两种map
+//
+// @param data
+// @param keys
+// @return {value,ok}
+//
+func lookup(data interface{}, keys []string) (interface{}, bool) {
+	for _, k := range keys {
+		switch m := data.(type) {
+		case map[string]interface{}:
+			v, ok := m[k]
+			if !ok {
+				return nil, false
+			}
+			data = v
+
+		case map[interface{}]interface{}:
+			v, ok := m[k]
+			if !ok {
+				return nil, false
+			}
+			data = v
+
+		default:
+			return nil, false
+		}
+	}
+
+	return data, nil != data
+}
+
+// yaml、环境变量和内存中的值，字符串按需要转换成其他类型
+type rawValue struct {
+	v  interface{}
+	ok bool
+}
+
+func (r rawValue) Exists() bool {
+	return r.ok
+}
+
+func (r rawValue) String(def string) string {
+	if !r.ok {
+		return def
+	}
+
+	switch v := r.v.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case map[string]interface{}, map[interface{}]interface{}, []interface{}:
+		return def
+	}
+
+	return fmt.Sprint(r.v)
+}
+
+func (r rawValue) Int64(def int64) int64 {
+	if !r.ok {
+		return def
+	}
+
+	switch v := r.v.(type) {
+	case int:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case int64:
+		return v
+	case uint:
+		return int64(v)
+	case uint32:
+		return int64(v)
+	case uint64:
+		return int64(v)
+	case float32:
+		return int64(v)
+	case float64:
+		return int64(v)
+	case string:
+		n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		if nil != err {
+			return def
+		}
+
+		return n
+	}
+
+	return def
+}
+
+func (r rawValue) Int(def int) int {
+	return int(r.Int64(int64(def)))
+}
+
+func (r rawValue) Float64(def float64) float64 {
+	if !r.ok {
+		return def
+	}
+
+	switch v := r.v.(type) {
+	case float64:
+		return v
+	case float32:
+		return float64(v)
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if nil != err {
+			return def
+		}
+
+		return f
+	}
+
+	return float64(r.Int64(int64(def)))
+}
+
+func (r rawValue) Bool(def bool) bool {
+	if !r.ok {
+		return def
+	}
+
+	switch v := r.v.(type) {
+	case bool:
+		return v
+	case string:
+		b, err := strconv.ParseBool(strings.TrimSpace(v))
+		if nil != err {
+			return def
+		}
+
+		return b
+	}
+
+	return def
+}
+
+func (r rawValue) StringSlice(def []string) []string {
+	if !r.ok {
+		return def
+	}
+
+	switch v := r.v.(type) {
+	case []string:
+		return v
+	case []interface{}:
+		ss := make([]string, 0, len(v))
+		for _, s := range v {
+			ss = append(ss, fmt.Sprint(s))
+		}
+
+		return ss
+	case string:
+		// 环境变量中使用逗号分隔
+		ss := make([]string, 0)
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); 0 < len(s) {
+				ss = append(ss, s)
+			}
+		}
+
+		return ss
+	}
+
+	return def
+}
@@ -0,0 +1,60 @@
+package conf
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_Layered(t *testing.T) {
+	dir, err := ioutil.TempDir("", "conf")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "common.yaml")
+	ioutil.WriteFile(path, []byte(`
+timeout: 5
+s2s:
+  zone: sh
+  tcp_port: 9001
+  secure: true
+  nodes: [a.svr, b.svr]
+`), 0644)
+
+	y, err := NewYaml(path)
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	os.Setenv("HEEGTEST_S2S_TCP_PORT", "9002")
+	defer os.Unsetenv("HEEGTEST_S2S_TCP_PORT")
+
+	mem := NewMemory(nil)
+	mem.Set("bj", "s2s", "zone")
+
+	p := NewLayered(mem, NewEnv("heegtest"), y)
+	if "bj" != p.Get("s2s", "zone").String("") {
+		t.Fatal("memory should override yaml")
+	}
+	if 9002 != p.Get("s2s", "tcp_port").Int(0) {
+		t.Fatal("env should override yaml")
+	}
+	if 5 != p.Get("timeout").Int(3) || !p.Get("s2s", "secure").Bool(false) {
+		t.Fatal("unexpected yaml values")
+	}
+	if nodes := p.Get("s2s", "nodes").StringSlice(nil); 2 != len(nodes) || "b.svr" != nodes[1] {
+		t.Fatalf("unexpected nodes %v", nodes)
+	}
+	if p.Get("s2s", "missing").Exists() || 10 != p.Get("s2s", "missing").Int(10) {
+		t.Fatal("missing key should use default")
+	}
+
+	// 重新加载后使用新的值
+	ioutil.WriteFile(path, []byte("timeout: 7\n"), 0644)
+	if err := y.Load(); nil != err || 7 != p.Get("timeout").Int(3) {
+		t.Fatalf("reload failed %v", err)
+	}
+}
@@ -0,0 +1,49 @@
+package conf
+
+import (
+	"os"
+	"strings"
+)
+
+// 环境变量中的配置，路径转换成大写并用下划线连接
+// 例如前缀HEEG时Get("s2s", "tcp_port")读取HEEG_S2S_TCP_PORT
+type env struct {
+	prefix string
+}
+
+// 创建环境变量配置来源
+//
+// @param prefix 	环境变量的前缀，可以为空
+// @return Provider
+//
+func NewEnv(prefix string) Provider {
+	return &env{
+		prefix: prefix,
+	}
+}
+
+// 配置路径对应的环境变量名
+//
+// @param keys
+// @return string
+//
+func (e *env) name(keys []string) string {
+	parts := make([]string, 0, len(keys)+1)
+	if 0 < len(e.prefix) {
+		parts = append(parts, e.prefix)
+	}
+	parts = append(parts, keys...)
+
+	name := strings.ToUpper(strings.Join(parts, "_"))
+	return strings.NewReplacer(".", "_", "-", "_").Replace(name)
+}
+
+func (e *env) Get(keys ...string) Value {
+	v, ok := os.LookupEnv(e.name(keys))
+
+	return rawValue{v: v, ok: ok}
+}
+
+func (e *env) String() string {
+	return "env:" + e.prefix
+}
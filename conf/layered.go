@@ -0,0 +1,50 @@
+package conf
+
+import (
+	"strings"
+)
+
+// 多个配置来源叠加，前面的优先级高
+// 例如NewLayered(NewMemory(nil), NewEnv("HEEG"), yaml, NewApollo(CommonNamespace))
+type layered struct {
+	providers []Provider
+}
+
+// 创建叠加的配置来源
+//
+// @param providers 	按优先级从高到低，nil会被忽略
+// @return Provider
+//
+func NewLayered(providers ...Provider) Provider {
+	l := &layered{
+		providers: make([]Provider, 0, len(providers)),
+	}
+	for _, p := range providers {
+		if nil != p {
+			l.providers = append(l.providers, p)
+		}
+	}
+
+	return l
+}
+
+// 返回第一个存在这个配置的来源中的值
+func (l *layered) Get(keys ...string) Value {
+	for _, p := range l.providers {
+		v := p.Get(keys...)
+		if v.Exists() {
+			return v
+		}
+	}
+
+	return rawValue{}
+}
+
+func (l *layered) String() string {
+	names := make([]string, 0, len(l.providers))
+	for _, p := range l.providers {
+		names = append(names, p.String())
+	}
+
+	return "layered[" + strings.Join(names, ",") + "]"
+}
@@ -0,0 +1,65 @@
+package conf
+
+import (
+	"sync"
+)
+
+// 内存中的配置，主要用于测试和程序中覆盖配置
+type Memory struct {
+	data map[string]interface{}
+	lock sync.RWMutex
+}
+
+// 创建内存配置来源
+//
+// @param data 	初始配置，可以为nil
+// @return *Memory
+//
+func NewMemory(data map[string]interface{}) *Memory {
+	if nil == data {
+		data = make(map[string]interface{})
+	}
+
+	return &Memory{
+		data: data,
+	}
+}
+
+// 设置配置，中间的路径不存在时自动创建
+//
+// @param value
+// @param keys
+//
+func (m *Memory) Set(value interface{}, keys ...string) {
+	if 0 == len(keys) {
+		return
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	data := m.data
+	for _, k := range keys[:len(keys)-1] {
+		next, ok := data[k].(map[string]interface{})
+		if !ok {
+			next = make(map[string]interface{})
+			data[k] = next
+		}
+
+		data = next
+	}
+
+	data[keys[len(keys)-1]] = value
+}
+
+func (m *Memory) Get(keys ...string) Value {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	v, ok := lookup(m.data, keys)
+	return rawValue{v: v, ok: ok}
+}
+
+func (m *Memory) String() string {
+	return "memory"
+}
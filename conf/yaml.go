@@ -0,0 +1,70 @@
+package conf
+
+import (
+	"io/ioutil"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 本地yaml文件中的配置，内容和apollo中的公共配置相同
+type Yaml struct {
+	path string
+
+	data map[string]interface{}
+	lock sync.RWMutex
+}
+
+// 创建yaml文件配置来源，创建时读取一次文件
+//
+// @param path
+// @return {*Yaml,error}
+//
+func NewYaml(path string) (*Yaml, error) {
+	y := &Yaml{
+		path: path,
+		data: make(map[string]interface{}),
+	}
+
+	err := y.Load()
+	if nil != err {
+		return nil, err
+	}
+
+	return y, nil
+}
+
+// 重新读取文件，解析失败时保留原来的配置
+//
+// @return error
+//
+func (y *Yaml) Load() error {
+	data, err := ioutil.ReadFile(y.path)
+	if nil != err {
+		return err
+	}
+
+	mp := make(map[string]interface{})
+	err = yaml.Unmarshal(data, &mp)
+	if nil != err {
+		return err
+	}
+
+	y.lock.Lock()
+	y.data = mp
+	y.lock.Unlock()
+
+	return nil
+}
+
+func (y *Yaml) Get(keys ...string) Value {
+	y.lock.RLock()
+	defer y.lock.RUnlock()
+
+	v, ok := lookup(y.data, keys)
+	return rawValue{v: v, ok: ok}
+}
+
+func (y *Yaml) String() string {
+	return "yaml:" + y.path
+}
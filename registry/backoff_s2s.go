@@ -0,0 +1,79 @@
+package registry
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// tcp连接的状态
+type ConnState int32
+
+const (
+	// 还没有连接
+	StateIdle ConnState = iota
+	// 正在连接
+	StateConnecting
+	// 已经连接
+	StateConnected
+	// 重试次数用完仍然没有连接上，后台会继续重连
+	StateDegraded
+)
+
+func (s ConnState) String() string {
+	switch s {
+	case StateIdle:
+		return "idle"
+	case StateConnecting:
+		return "connecting"
+	case StateConnected:
+		return "connected"
+	case StateDegraded:
+		return "degraded"
+	}
+
+	return "unknown"
+}
+
+// 重连策略，指数退避加随机抖动
+type Backoff struct {
+	// 第一次重试前等待的时间
+	Initial time.Duration
+	// 最长的等待时间
+	Max time.Duration
+	// 每次等待时间的倍数
+	Multiplier float64
+	// 随机抖动的比例，0.2表示上下浮动20%
+	Jitter float64
+	// 最多尝试的次数，小于等于0不限制
+	MaxAttempts int
+	// 最长的重连时间，小于等于0不限制
+	Deadline time.Duration
+}
+
+var DefaultBackoff = Backoff{
+	Initial:     200 * time.Millisecond,
+	Max:         10 * time.Second,
+	Multiplier:  2,
+	Jitter:      0.2,
+	MaxAttempts: 8,
+	Deadline:    30 * time.Second,
+}
+
+// 第attempt次重试前等待的时间，attempt从0开始
+//
+// @param attempt
+// @return time.Duration
+//
+func (b Backoff) Delay(attempt int) time.Duration {
+	delay := float64(b.Initial) * math.Pow(b.Multiplier, float64(attempt))
+	if 0 < b.Max && delay > float64(b.Max) {
+		delay = float64(b.Max)
+	}
+
+	if 0 < b.Jitter {
+		delay = delay * (1 + b.Jitter*(2*rand.Float64()-1))
+	}
+
+	return time.Duration(delay)
+}
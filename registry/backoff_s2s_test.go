@@ -0,0 +1,52 @@
+package registry
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_BackoffDelay(t *testing.T) {
+	b := Backoff{
+		Initial:    100 * time.Millisecond,
+		Max:        time.Second,
+		Multiplier: 2,
+	}
+
+	cases := []struct {
+		attempt int
+		delay   time.Duration
+	}{
+		{0, 100 * time.Millisecond},
+		{1, 200 * time.Millisecond},
+		{2, 400 * time.Millisecond},
+		{3, 800 * time.Millisecond},
+		// 超过Max后不再增长
+		{4, time.Second},
+		{10, time.Second},
+		{100, time.Second},
+	}
+
+	for _, c := range cases {
+		if delay := b.Delay(c.attempt); c.delay != delay {
+			t.Errorf("attempt %d: expect %v, got %v", c.attempt, c.delay, delay)
+		}
+	}
+
+	// 抖动在上下Jitter的比例内
+	b.Jitter = 0.2
+	for _, c := range cases {
+		min := time.Duration(float64(c.delay) * 0.8)
+		max := time.Duration(float64(c.delay) * 1.2)
+		for i := 0; i < 100; i++ {
+			if delay := b.Delay(c.attempt); delay < min || delay > max {
+				t.Fatalf("attempt %d: delay %v out of [%v, %v]", c.attempt, delay, min, max)
+			}
+		}
+	}
+
+	// 没有设置Max时不限制
+	b = Backoff{Initial: time.Millisecond, Multiplier: 10}
+	if delay := b.Delay(3); time.Second != delay {
+		t.Fatalf("expect 1s without max, got %v", delay)
+	}
+}
@@ -0,0 +1,215 @@
+package registry
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"io"
+	"net"
+
+	"github.com/heegspace/heegrpc/registry/s2spb"
+	"go-micro.dev/v4/logger"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+)
+
+// tcp消息的协议版本
+const (
+	// 每条消息单独gob编码，旧版本s2s使用
+	ProtoGob = 0
+	// 第一个字节为0，第二个字节为版本号，后面是protobuf编码的消息
+	// gob编码的消息第一个字节是长度，不会为0
+	ProtoV1 = 1
+
+	// 当前支持的最高版本
+	ProtoLatest = ProtoV1
+)
+
+const (
+	// 帧头的长度
+	frameHeaderSize = 4
+	// 单个帧的最大长度，超过时认为数据错误
+	maxFrameSize = 64 << 20
+)
+
+var errBadFrame = errors.New("s2s bad frame")
+
+// 按协议版本编码请求
+//
+// @param version
+// @param req
+// @return {[]byte,error}
+//
+func EncodeReq(version int, req *StreamReq) ([]byte, error) {
+	if ProtoGob == version {
+		return gobEncode(req)
+	}
+
+	data, err := proto.Marshal(&s2spb.StreamReq{
+		Cmd:   req.Cmd,
+		Data:  req.Data,
+		Tag:   req.Tag,
+		Extra: req.Extra,
+	})
+	if nil != err {
+		return nil, err
+	}
+
+	return append([]byte{0, byte(version)}, data...), nil
+}
+
+// 解码请求，根据第一个字节判断协议版本
+//
+// @param data
+// @return {StreamReq,version,error}
+//
+func DecodeReq(data []byte) (*StreamReq, int, error) {
+	var req StreamReq
+	if 0 == len(data) || 0 != data[0] {
+		err := gob.NewDecoder(bytes.NewReader(data)).Decode(&req)
+
+		return &req, ProtoGob, err
+	}
+	if 2 > len(data) {
+		return nil, 0, errBadFrame
+	}
+
+	var msg s2spb.StreamReq
+	err := proto.Unmarshal(data[2:], &msg)
+	if nil != err {
+		return nil, 0, err
+	}
+
+	req.Cmd = msg.Cmd
+	req.Data = msg.Data
+	req.Tag = msg.Tag
+	req.Extra = msg.Extra
+	return &req, int(data[1]), nil
+}
+
+// 按协议版本编码响应
+//
+// @param version
+// @param res
+// @return {[]byte,error}
+//
+func EncodeRes(version int, res *StreamRes) ([]byte, error) {
+	if ProtoGob == version {
+		return gobEncode(res)
+	}
+
+	data, err := proto.Marshal(&s2spb.StreamRes{
+		Cmd:  res.Cmd,
+		Code: res.Code,
+		Data: res.Data,
+		Tag:  res.Tag,
+	})
+	if nil != err {
+		return nil, err
+	}
+
+	return append([]byte{0, byte(version)}, data...), nil
+}
+
+// 解码响应，根据第一个字节判断协议版本
+//
+// @param data
+// @return {StreamRes,version,error}
+//
+func DecodeRes(data []byte) (*StreamRes, int, error) {
+	var res StreamRes
+	if 0 == len(data) || 0 != data[0] {
+		err := gob.NewDecoder(bytes.NewReader(data)).Decode(&res)
+
+		return &res, ProtoGob, err
+	}
+	if 2 > len(data) {
+		return nil, 0, errBadFrame
+	}
+
+	var msg s2spb.StreamRes
+	err := proto.Unmarshal(data[2:], &msg)
+	if nil != err {
+		return nil, 0, err
+	}
+
+	res.Cmd = msg.Cmd
+	res.Code = msg.Code
+	res.Data = msg.Data
+	res.Tag = msg.Tag
+	return &res, int(data[1]), nil
+}
+
+func gobEncode(obj interface{}) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	enc := gob.NewEncoder(buf)
+	err := enc.Encode(obj)
+	if nil != err {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// 写入一帧数据，帧头为4字节大端的数据长度，和appcom的分帧相同
+// tcp和tls连接都使用，长度和数据一次写入，多个协程可以同时写同一个连接
+//
+// @param conn
+// @param data
+// @return error
+//
+func WriteFrame(conn net.Conn, data []byte) error {
+	if maxFrameSize < len(data) {
+		return errBadFrame
+	}
+
+	buf := make([]byte, frameHeaderSize+len(data))
+	binary.BigEndian.PutUint32(buf, uint32(len(data)))
+	copy(buf[frameHeaderSize:], data)
+
+	_, err := conn.Write(buf)
+	return err
+}
+
+// 循环读取连接上的数据帧，连接断开或者数据帧错误后调用closed并返回
+//
+// @param conn
+// @param recv 	收到一帧数据
+// @param closed 	连接断开
+//
+func ReadFrames(conn net.Conn, recv func(conn net.Conn, data []byte) error, closed func(conn net.Conn)) {
+	defer closed(conn)
+
+	reader := bufio.NewReader(conn)
+	header := make([]byte, frameHeaderSize)
+	for {
+		_, err := io.ReadFull(reader, header)
+		if nil != err {
+			if io.EOF != err {
+				logger.Debug("s2s read frame err", zap.Any("remote", conn.RemoteAddr()), zap.Error(err))
+			}
+
+			return
+		}
+
+		size := binary.BigEndian.Uint32(header)
+		if maxFrameSize < size {
+			logger.Error("s2s read frame err", zap.Any("remote", conn.RemoteAddr()), zap.Any("size", size), zap.Error(errBadFrame))
+
+			return
+		}
+
+		data := make([]byte, size)
+		_, err = io.ReadFull(reader, data)
+		if nil != err {
+			logger.Debug("s2s read frame err", zap.Any("remote", conn.RemoteAddr()), zap.Error(err))
+
+			return
+		}
+
+		// 单个帧处理失败不断开连接
+		recv(conn, data)
+	}
+}
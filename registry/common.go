@@ -4,83 +4,92 @@ package registry
 import (
 	"crypto/rand"
 	"encoding/json"
-	"time"
+	"os"
 
-	"github.com/shirou/gopsutil/v3/cpu"
-	"github.com/shirou/gopsutil/v3/disk"
-	"github.com/shirou/gopsutil/v3/host"
-	"github.com/shirou/gopsutil/v3/mem"
-	"go-micro.dev/v4/util/addr"
+	"go-micro.dev/v4/registry"
 )
 
-type SysInfo struct {
-	HostName   string   `json:"hostname,omitempty"`
-	OS         string   `json:"os,omitempty"`
-	CpuNum     int      `json:"cpu_num,omitempty"`
-	CpuPercent float64  `json:"cpu_percent,omitempty"`
-	MemTotal   uint64   `json:"mem_total,omitempty"`
-	MemUsed    uint64   `json:"mem_used,omitempty"`
-	DiskTotal  uint64   `json:"disk_total,omitempty"`
-	DiskUsed   uint64   `json:"disk_used,omitempty"`
-	Ips        []string `json:"ips,omitempty"`
-}
+// 检查文件是否存在
+//
+// @param path
+// @return {bool}
+//
+func fileExist(path string) bool {
+	_, err := os.Lstat(path)
 
-func getsysinfo() string {
-	var sysinfo SysInfo
+	return !os.IsNotExist(err)
+}
 
-	hostinfo, err := host.Info()
-	if nil == err {
-		sysinfo.HostName = hostinfo.Hostname
-		sysinfo.OS = hostinfo.OS
+func getRandomTag() string {
+	alphanum := "0123456789"
+	var bytes = make([]byte, 32)
+	rand.Read(bytes)
+	for i, b := range bytes {
+		bytes[i] = alphanum[b%byte(len(alphanum))]
 	}
+	return string(bytes)
+}
+
+func toJson(obj interface{}) string {
+	data, _ := json.Marshal(&obj)
+
+	return string(data)
+}
 
-	count, err := cpu.Counts(true)
-	if nil == err {
-		sysinfo.CpuNum = count
+func copyMetadata(md map[string]string) map[string]string {
+	if nil == md {
+		return nil
 	}
 
-	percent, err := cpu.Percent(time.Second, false)
-	if nil == err && 0 < len(percent) {
-		sysinfo.CpuPercent = percent[0]
+	res := make(map[string]string, len(md))
+	for k, v := range md {
+		res[k] = v
 	}
 
-	memInfo, err := mem.VirtualMemory()
-	if nil == err && nil != memInfo {
-		sysinfo.MemTotal = memInfo.Total
-		sysinfo.MemUsed = memInfo.Used
+	return res
+}
+
+// 深拷贝服务信息，调用方修改返回的服务不会影响缓存
+// go-micro的cache会在返回的服务上追加节点
+//
+// @param svc
+// @return *registry.Service
+//
+func copyService(svc *registry.Service) *registry.Service {
+	if nil == svc {
+		return nil
 	}
 
-	parts, err := disk.Partitions(true)
-	if nil == err && 0 < len(parts) {
-		for _, v := range parts {
-			diskInfo, err := disk.Usage(v.Mountpoint)
-			if nil != err {
-				continue
-			}
+	res := *svc
+	res.Metadata = copyMetadata(svc.Metadata)
 
-			sysinfo.DiskTotal = sysinfo.DiskTotal + diskInfo.Total
-			sysinfo.DiskUsed = sysinfo.DiskUsed + diskInfo.Used
-		}
+	res.Nodes = make([]*registry.Node, 0, len(svc.Nodes))
+	for _, n := range svc.Nodes {
+		node := *n
+		node.Metadata = copyMetadata(n.Metadata)
+		res.Nodes = append(res.Nodes, &node)
+	}
 
+	res.Endpoints = make([]*registry.Endpoint, 0, len(svc.Endpoints))
+	for _, e := range svc.Endpoints {
+		ep := *e
+		ep.Metadata = copyMetadata(e.Metadata)
+		res.Endpoints = append(res.Endpoints, &ep)
 	}
 
-	sysinfo.Ips = addr.IPs()
-	info, _ := json.Marshal(sysinfo)
-	return string(info)
+	return &res
 }
 
-func getRandomTag() string {
-	alphanum := "0123456789"
-	var bytes = make([]byte, 32)
-	rand.Read(bytes)
-	for i, b := range bytes {
-		bytes[i] = alphanum[b%byte(len(alphanum))]
+// 深拷贝多个服务
+//
+// @param services
+// @return []*registry.Service
+//
+func copyServices(services []*registry.Service) []*registry.Service {
+	res := make([]*registry.Service, 0, len(services))
+	for _, svc := range services {
+		res = append(res, copyService(svc))
 	}
-	return string(bytes)
-}
 
-func toJson(obj interface{}) string {
-	data, _ := json.Marshal(&obj)
-
-	return string(data)
+	return res
 }
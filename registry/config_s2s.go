@@ -0,0 +1,67 @@
+package registry
+
+import (
+	"context"
+	"sync"
+
+	"github.com/heegspace/heegrpc/conf"
+	"go-micro.dev/v4/registry"
+)
+
+type configKey struct{}
+
+// 设置读取s2s配置的来源，没有设置时使用conf.Default()
+//
+// @param p
+// @return Option
+//
+func Config(p conf.Provider) registry.Option {
+	return func(o *registry.Options) {
+		if nil == o.Context {
+			o.Context = context.Background()
+		}
+
+		o.Context = context.WithValue(o.Context, configKey{}, p)
+	}
+}
+
+var (
+	provider  conf.Provider
+	providerl sync.RWMutex
+)
+
+// 使用registry选项中的配置来源，tcp连接等全局对象也使用这个配置
+//
+// @param opts
+//
+func setProvider(opts registry.Options) {
+	if nil == opts.Context {
+		return
+	}
+
+	p, ok := opts.Context.Value(configKey{}).(conf.Provider)
+	if !ok || nil == p {
+		return
+	}
+
+	providerl.Lock()
+	provider = p
+	providerl.Unlock()
+}
+
+// 读取s2s下的配置
+//
+// @param key
+// @return conf.Value
+//
+func s2sConfig(key string) conf.Value {
+	providerl.RLock()
+	p := provider
+	providerl.RUnlock()
+
+	if nil == p {
+		p = conf.Default()
+	}
+
+	return p.Get("s2s", key)
+}
@@ -0,0 +1,136 @@
+package registry
+
+import (
+	"go-micro.dev/v4/logger"
+	"go-micro.dev/v4/registry"
+	"go.uber.org/zap"
+)
+
+// 节点状态，记录在节点元数据的state中
+const (
+	// 正常接收请求
+	NodeActive = "active"
+	// 正在下线，不再分配新的请求，已有的请求继续处理
+	NodeDraining = "draining"
+)
+
+// 节点元数据中记录状态的key
+const nodeStateKey = "state"
+
+// 服务当前的节点状态
+//
+// @param name 	服务名
+// @return string
+//
+func (s *proxy) nodeState(name string) string {
+	s.llock.Lock()
+	defer s.llock.Unlock()
+
+	if s.drains[""] || s.drains[name] {
+		return NodeDraining
+	}
+
+	return NodeActive
+}
+
+// 复制服务节点并带上节点状态，不修改调用方的节点
+//
+// @param svc
+//
+func (s *proxy) withState(svc *registry.Service) {
+	state := s.nodeState(svc.Name)
+
+	nodes := make([]*registry.Node, 0, len(svc.Nodes))
+	for _, n := range svc.Nodes {
+		node := *n
+		node.Metadata = make(map[string]string, len(n.Metadata)+1)
+		for k, v := range n.Metadata {
+			node.Metadata[k] = v
+		}
+		node.Metadata[nodeStateKey] = state
+
+		nodes = append(nodes, &node)
+	}
+	svc.Nodes = nodes
+}
+
+// 修改服务的节点状态，并立即重新注册让调用方更新节点
+//
+// @param name 	服务名，为空时修改所有服务
+// @param drain
+// @return error
+//
+func (s *proxy) setDrain(name string, drain bool) error {
+	s.llock.Lock()
+	if drain {
+		s.drains[name] = true
+	} else if 0 == len(name) {
+		// 取消全部服务的下线
+		s.drains = make(map[string]bool)
+	} else {
+		delete(s.drains, name)
+	}
+	s.llock.Unlock()
+
+	var gerr error
+	for _, l := range s.findLocals(name) {
+		err := s.registerLocal(l, false)
+		if nil != err {
+			logger.Warn("update node state err", zap.Any("name", l.name), zap.Any("drain", drain), zap.Error(err))
+
+			gerr = err
+			continue
+		}
+
+		logger.Info("update node state", zap.Any("name", l.name), zap.Any("state", s.nodeState(l.name)))
+	}
+
+	return gerr
+}
+
+// 节点进入下线状态，调用方不再选择这个节点，进程继续处理已有的请求
+//
+// @param name 	服务名，为空时所有服务都下线
+// @return error
+//
+func Drain(name string) error {
+	if nil == gs {
+		return errNoRegistry
+	}
+
+	return gs.setDrain(name, true)
+}
+
+// 节点恢复正常状态，通过空的服务名下线时也需要使用空的服务名恢复
+//
+// @param name 	服务名，为空时恢复所有服务
+// @return error
+//
+func Undrain(name string) error {
+	if nil == gs {
+		return errNoRegistry
+	}
+
+	return gs.setDrain(name, false)
+}
+
+// 服务的节点状态，active或draining
+//
+// @param name
+// @return string
+//
+func NodeState(name string) string {
+	if nil == gs {
+		return NodeActive
+	}
+
+	return gs.nodeState(name)
+}
+
+// GetService和watch默认使用的过滤条件，总是排除正在下线的节点
+//
+// @return {[]Filter}
+//
+func (s *proxy) defaultFilters() []Filter {
+	return append([]Filter{ExcludeDraining()}, contextFilters(s.opts.Context)...)
+}
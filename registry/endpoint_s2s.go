@@ -0,0 +1,220 @@
+package registry
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go-micro.dev/v4/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	// 健康检查连接的超时时间
+	healthTimeout = 2 * time.Second
+)
+
+// s2s的tcp地址
+type endpoint struct {
+	addr    string
+	healthy int32
+}
+
+func (e *endpoint) isHealthy() bool {
+	return 1 == atomic.LoadInt32(&e.healthy)
+}
+
+func (e *endpoint) setHealthy(healthy bool) {
+	v := int32(0)
+	if healthy {
+		v = 1
+	}
+
+	old := atomic.SwapInt32(&e.healthy, v)
+	if old != v {
+		logger.Info("s2s endpoint health changed", zap.Any("addr", e.addr), zap.Any("healthy", healthy))
+	}
+}
+
+// 解析逗号分隔的地址列表，去掉重复和空的地址
+//
+// @param addrs
+// @return {[]endpoint}
+//
+func parseEndpoints(addrs ...string) []*endpoint {
+	seen := make(map[string]bool)
+	endpoints := make([]*endpoint, 0)
+	for _, v := range addrs {
+		for _, addr := range strings.Split(v, ",") {
+			addr = strings.TrimSpace(addr)
+			if 0 == len(addr) || seen[addr] {
+				continue
+			}
+
+			seen[addr] = true
+			endpoints = append(endpoints, &endpoint{addr: addr, healthy: 1})
+		}
+	}
+
+	return endpoints
+}
+
+// 选择第attempt次连接使用的地址，从当前地址的下一个开始优先选择健康的地址
+// 所有地址都不健康时按顺序轮询
+//
+// @param attempt
+// @return *endpoint
+//
+func (this *tcpS2s) pickEndpoint(attempt int) *endpoint {
+	this.eplock.Lock()
+	defer this.eplock.Unlock()
+
+	size := len(this.endpoints)
+	if 0 == attempt && this.endpoints[this.current].isHealthy() {
+		return this.endpoints[this.current]
+	}
+
+	for i := 1; i <= size; i++ {
+		idx := (this.current + i) % size
+		if this.endpoints[idx].isHealthy() {
+			this.current = idx
+			return this.endpoints[idx]
+		}
+	}
+
+	this.current = (this.current + 1) % size
+	return this.endpoints[this.current]
+}
+
+// 当前使用的s2s地址
+//
+// @return string
+//
+func (this *tcpS2s) Addr() string {
+	this.eplock.Lock()
+	defer this.eplock.Unlock()
+
+	if 0 == len(this.endpoints) {
+		return ""
+	}
+
+	return this.endpoints[this.current].addr
+}
+
+// 所有s2s地址的健康状态
+//
+// @return map[addr]healthy
+//
+func (this *tcpS2s) Endpoints() map[string]bool {
+	this.eplock.Lock()
+	defer this.eplock.Unlock()
+
+	result := make(map[string]bool)
+	for _, e := range this.endpoints {
+		result[e.addr] = e.isHealthy()
+	}
+
+	return result
+}
+
+// 定时检查所有地址是否可以连接
+//
+// @param interval
+//
+func (this *tcpS2s) healthCheck(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		}
+
+		wg := sync.WaitGroup{}
+		for _, e := range this.endpoints {
+			wg.Add(1)
+			go func(e *endpoint) {
+				defer wg.Done()
+
+				// 和正常连接一样使用tls，只检查端口会漏掉握手失败
+				ctx, cancel := context.WithTimeout(context.Background(), healthTimeout)
+				defer cancel()
+
+				conn, err := this.dial(ctx, e.addr)
+				if nil != err {
+					e.setHealthy(false)
+
+					return
+				}
+
+				conn.Close()
+				e.setHealthy(true)
+			}(e)
+		}
+		wg.Wait()
+
+		// 当前地址不健康时切换到健康的地址
+		this.eplock.Lock()
+		current := this.endpoints[this.current]
+		this.eplock.Unlock()
+		if conn := this.GetConn(); nil != conn && !current.isHealthy() {
+			logger.Warn("s2s endpoint unhealthy, failover", zap.Any("addr", current.addr))
+
+			go this.reconnect(context.Background(), conn)
+		}
+	}
+}
+
+// 在新连接上重新发送还在等待响应的请求，切换连接后原连接上的响应已经丢失
+// 正在发送的请求也可能在这里写入，每个请求在同一个连接上只写一次
+//
+// @param conn 	新的连接
+//
+func (this *tcpS2s) resend(conn net.Conn) {
+	this.pending.lock.Lock()
+	reqs := make([]*StreamReq, 0, len(this.pending.calls))
+	for _, call := range this.pending.calls {
+		if call.conn == conn {
+			continue
+		}
+
+		call.conn = conn
+		reqs = append(reqs, resign(call.req, call.key))
+	}
+	this.pending.lock.Unlock()
+
+	for _, req := range reqs {
+		data, err := EncodeReq(this.Version(), req)
+		if nil == err {
+			err = WriteFrame(conn, data)
+		}
+		if nil != err {
+			logger.Warn("resend to s2s err", zap.Any("cmd", req.Cmd), zap.Any("tag", req.Tag), zap.Error(err))
+		}
+	}
+}
+
+// 使用新的nonce重新签名，s2s会拒绝使用过的nonce
+// 返回签名后的副本，不修改正在发送的请求
+//
+// @param req
+// @param key 	为nil时不签名，直接返回req
+// @return *StreamReq
+//
+func resign(req *StreamReq, key []byte) *StreamReq {
+	if nil == key {
+		return req
+	}
+
+	next := *req
+	next.Extra = make(map[string]string, len(req.Extra))
+	for k, v := range req.Extra {
+		next.Extra[k] = v
+	}
+	SignRequest(key, &next)
+
+	return &next
+}
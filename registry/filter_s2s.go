@@ -0,0 +1,332 @@
+package registry
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"go-micro.dev/v4/registry"
+	"go-micro.dev/v4/selector"
+)
+
+// 节点过滤条件，返回true表示保留节点
+type Filter func(svc *registry.Service, node *registry.Node) bool
+
+type filtersKey struct{}
+
+// 设置所有GetService和Watch默认使用的过滤条件
+//
+// @param filters
+// @return Option
+//
+func Filters(filters ...Filter) registry.Option {
+	return func(o *registry.Options) {
+		if nil == o.Context {
+			o.Context = context.Background()
+		}
+
+		o.Context = context.WithValue(o.Context, filtersKey{}, filters)
+	}
+}
+
+// 设置单次GetService使用的过滤条件，和默认过滤条件同时生效
+//
+// @param filters
+// @return GetOption
+//
+func GetFilter(filters ...Filter) registry.GetOption {
+	return func(o *registry.GetOptions) {
+		if nil == o.Context {
+			o.Context = context.Background()
+		}
+
+		prev, _ := o.Context.Value(filtersKey{}).([]Filter)
+		o.Context = context.WithValue(o.Context, filtersKey{}, append(prev, filters...))
+	}
+}
+
+// 从context中获取过滤条件
+//
+// @param ctx
+// @return {[]Filter}
+//
+func contextFilters(ctx context.Context) []Filter {
+	if nil == ctx {
+		return nil
+	}
+
+	filters, _ := ctx.Value(filtersKey{}).([]Filter)
+	return filters
+}
+
+// 转换为go-micro selector使用的过滤器，可以通过selector.WithFilter使用
+//
+// @param filters
+// @return selector.Filter
+//
+func SelectorFilter(filters ...Filter) selector.Filter {
+	return func(services []*registry.Service) []*registry.Service {
+		return applyFilters(services, filters)
+	}
+}
+
+// 按版本约束过滤，支持1.2.3、1.2.x、>=1.2.0、<2.0.0、!=1.2.3
+// 多个约束使用逗号分隔，需要同时满足
+//
+// @param constraint
+// @return Filter
+//
+func Version(constraint string) Filter {
+	return func(svc *registry.Service, node *registry.Node) bool {
+		return matchVersion(svc.Version, constraint)
+	}
+}
+
+// 按元数据过滤，节点的元数据优先，没有时使用服务的元数据
+//
+// @param key
+// @param value
+// @return Filter
+//
+func Metadata(key, value string) Filter {
+	return func(svc *registry.Service, node *registry.Node) bool {
+		if nil != node.Metadata {
+			if v, ok := node.Metadata[key]; ok {
+				return v == value
+			}
+		}
+		if nil != svc.Metadata {
+			if v, ok := svc.Metadata[key]; ok {
+				return v == value
+			}
+		}
+
+		return false
+	}
+}
+
+// 按机房过滤，节点的zone元数据优先，没有时使用服务的元数据
+// 都没有zone时保留节点，还没有发布zone的服务不会因为调用方开启zone而找不到节点
+//
+// @param zone
+// @return Filter
+//
+func Zone(zone string) Filter {
+	return func(svc *registry.Service, node *registry.Node) bool {
+		if nil != node.Metadata {
+			if v, ok := node.Metadata["zone"]; ok {
+				return v == zone
+			}
+		}
+		if nil != svc.Metadata {
+			if v, ok := svc.Metadata["zone"]; ok {
+				return v == zone
+			}
+		}
+
+		return true
+	}
+}
+
+// 排除正在下线的节点，节点元数据state为draining
+//
+// @return Filter
+//
+func ExcludeDraining() Filter {
+	return func(svc *registry.Service, node *registry.Node) bool {
+		if nil == node.Metadata {
+			return true
+		}
+
+		return NodeDraining != node.Metadata[nodeStateKey]
+	}
+}
+
+// 过滤服务节点，没有节点的服务版本会被去掉
+//
+// @param services
+// @param filters
+// @return {[]Service}
+//
+func applyFilters(services []*registry.Service, filters []Filter) []*registry.Service {
+	if 0 == len(filters) {
+		return services
+	}
+
+	result := make([]*registry.Service, 0, len(services))
+	for _, svc := range services {
+		kept, _ := splitNodes(svc, filters)
+		if 0 == len(kept) {
+			continue
+		}
+
+		item := *svc
+		item.Nodes = kept
+		result = append(result, &item)
+	}
+
+	return result
+}
+
+// 按过滤条件把节点分为保留和排除两部分
+//
+// @param svc
+// @param filters
+// @return {kept,excluded}
+//
+func splitNodes(svc *registry.Service, filters []Filter) ([]*registry.Node, []*registry.Node) {
+	kept := make([]*registry.Node, 0, len(svc.Nodes))
+	excluded := make([]*registry.Node, 0)
+	for _, node := range svc.Nodes {
+		ok := true
+		for _, f := range filters {
+			if !f(svc, node) {
+				ok = false
+				break
+			}
+		}
+
+		if ok {
+			kept = append(kept, node)
+		} else {
+			excluded = append(excluded, node)
+		}
+	}
+
+	return kept, excluded
+}
+
+// 过滤watch事件，被排除的节点转换为delete事件，让selector删除缓存中的节点
+//
+// @param res
+// @param filters
+// @return {[]Result}
+//
+func filterResult(res *registry.Result, filters []Filter) []*registry.Result {
+	if 0 == len(filters) || "delete" == res.Action {
+		return []*registry.Result{res}
+	}
+
+	results := make([]*registry.Result, 0, 2)
+	kept, excluded := splitNodes(res.Service, filters)
+	if 0 < len(kept) {
+		svc := *res.Service
+		svc.Nodes = kept
+		results = append(results, &registry.Result{Action: res.Action, Service: &svc})
+	}
+	if 0 < len(excluded) {
+		svc := *res.Service
+		svc.Nodes = excluded
+		results = append(results, &registry.Result{Action: "delete", Service: &svc})
+	}
+
+	return results
+}
+
+// 检查版本是否满足约束
+//
+// @param version
+// @param constraint
+// @return bool
+//
+func matchVersion(version, constraint string) bool {
+	for _, c := range strings.Split(constraint, ",") {
+		c = strings.TrimSpace(c)
+		if 0 == len(c) {
+			continue
+		}
+
+		op := ""
+		for _, prefix := range []string{">=", "<=", "!=", ">", "<", "="} {
+			if strings.HasPrefix(c, prefix) {
+				op = prefix
+				c = strings.TrimSpace(c[len(prefix):])
+				break
+			}
+		}
+
+		var ok bool
+		switch op {
+		case ">=":
+			ok = 0 <= compareVersion(version, c)
+		case "<=":
+			ok = 0 >= compareVersion(version, c)
+		case ">":
+			ok = 0 < compareVersion(version, c)
+		case "<":
+			ok = 0 > compareVersion(version, c)
+		case "!=":
+			ok = !wildcardVersion(version, c)
+		default:
+			ok = wildcardVersion(version, c)
+		}
+
+		if !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// 版本相等比较，约束中的x或*匹配任意值
+//
+// @param version
+// @param pattern
+// @return bool
+//
+func wildcardVersion(version, pattern string) bool {
+	vs := strings.Split(version, ".")
+	ps := strings.Split(pattern, ".")
+	for i, p := range ps {
+		if "x" == p || "X" == p || "*" == p {
+			return true
+		}
+		if i >= len(vs) || vs[i] != p {
+			return false
+		}
+	}
+
+	return len(vs) == len(ps)
+}
+
+// 按数字比较版本，非数字部分按字符串比较
+//
+// @param a
+// @param b
+// @return int 	-1、0、1
+//
+func compareVersion(a, b string) int {
+	as := strings.Split(strings.TrimPrefix(a, "v"), ".")
+	bs := strings.Split(strings.TrimPrefix(b, "v"), ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		av, bv := "0", "0"
+		if i < len(as) {
+			av = as[i]
+		}
+		if i < len(bs) {
+			bv = bs[i]
+		}
+
+		an, aerr := strconv.Atoi(av)
+		bn, berr := strconv.Atoi(bv)
+		if nil == aerr && nil == berr {
+			if an != bn {
+				if an < bn {
+					return -1
+				}
+				return 1
+			}
+			continue
+		}
+
+		if av != bv {
+			if av < bv {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	return 0
+}
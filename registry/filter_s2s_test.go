@@ -0,0 +1,56 @@
+package registry
+
+import (
+	"strings"
+	"testing"
+
+	"go-micro.dev/v4/registry"
+)
+
+func Test_matchVersion(t *testing.T) {
+	cases := []struct {
+		version    string
+		constraint string
+		match      bool
+	}{
+		{"1.2.3", "1.2.3", true},
+		{"1.2.3", "1.2.x", true},
+		{"1.3.0", "1.2.*", false},
+		{"1.10.0", ">=1.2.0", true},
+		{"1.10.0", ">=1.2.0,<1.10.0", false},
+		{"2.0.0", "!=2.0.0", false},
+		{"0.0.1", "", true},
+	}
+
+	for _, c := range cases {
+		if matchVersion(c.version, c.constraint) != c.match {
+			t.Errorf("matchVersion(%s, %s) != %v", c.version, c.constraint, c.match)
+		}
+	}
+}
+
+func Test_Zone(t *testing.T) {
+	services := []*registry.Service{
+		{
+			Name: "a.svr",
+			Nodes: []*registry.Node{
+				{Id: "sh", Metadata: map[string]string{"zone": "sh"}},
+				{Id: "bj", Metadata: map[string]string{"zone": "bj"}},
+				{Id: "none"},
+			},
+		},
+		{Name: "b.svr", Metadata: map[string]string{"zone": "bj"}, Nodes: []*registry.Node{{Id: "svc-bj"}}},
+		{Name: "c.svr", Nodes: []*registry.Node{{Id: "old"}}},
+	}
+
+	// 还没有发布zone的节点和服务保留，其他机房的节点排除
+	ids := make([]string, 0)
+	for _, svc := range applyFilters(services, []Filter{Zone("sh")}) {
+		for _, n := range svc.Nodes {
+			ids = append(ids, n.Id)
+		}
+	}
+	if "sh,none,old" != strings.Join(ids, ",") {
+		t.Fatalf("unexpected nodes %v", ids)
+	}
+}
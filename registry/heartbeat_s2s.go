@@ -0,0 +1,158 @@
+package registry
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go-micro.dev/v4/logger"
+	"go-micro.dev/v4/registry"
+	"go.uber.org/zap"
+)
+
+const (
+	// 检查心跳的间隔
+	heartbeatTick = time.Second
+)
+
+// 本地注册的服务，除了send以外的字段使用proxy.llock保护
+type localService struct {
+	// 服务名，和节点id一起作为key，不会改变
+	name string
+	svc  *registry.Service
+	ttl  time.Duration
+
+	// 下次发送心跳的时间
+	next time.Time
+	// 暂时离开，不发送心跳，Rejoin后恢复
+	held bool
+
+	// 发送注册和注销互斥，Leave等正在发送的心跳完成后再注销
+	send sync.Mutex
+}
+
+// 本地服务的唯一标识，服务名+节点id
+//
+// @param svc
+// @return string
+//
+func localKey(svc *registry.Service) string {
+	ids := make([]string, 0, len(svc.Nodes))
+	for _, n := range svc.Nodes {
+		ids = append(ids, n.Id)
+	}
+	sort.Strings(ids)
+
+	return svc.Name + "|" + strings.Join(ids, ",")
+}
+
+// 心跳间隔为ttl的1/3，保证s2s在过期前至少收到两次心跳
+//
+// @param ttl
+// @return time.Duration
+//
+func heartbeatInterval(ttl time.Duration) time.Duration {
+	interval := ttl / 3
+	if interval < heartbeatTick {
+		interval = heartbeatTick
+	}
+
+	return interval
+}
+
+// 记录本地注册的服务，用于发送心跳和重连后重新注册
+// go-micro按RegisterInterval调用Register时会推迟下次心跳，已经暂时离开的服务保持离开
+//
+// @param svc
+// @param ttl
+// @return *localService
+//
+func (s *proxy) track(svc *registry.Service, ttl time.Duration) *localService {
+	s.llock.Lock()
+	defer s.llock.Unlock()
+
+	key := localKey(svc)
+	l, ok := s.locals[key]
+	if !ok {
+		l = &localService{name: svc.Name}
+		s.locals[key] = l
+	}
+	l.svc = svc
+	l.ttl = ttl
+	l.next = time.Now().Add(heartbeatInterval(ttl))
+
+	return l
+}
+
+func (s *proxy) untrack(svc *registry.Service) {
+	s.llock.Lock()
+	defer s.llock.Unlock()
+
+	delete(s.locals, localKey(svc))
+}
+
+// 获取需要发送注册信息的本地服务
+//
+// @param all 	为true时返回所有服务，否则只返回心跳到期且设置了ttl的服务，暂时离开的服务不返回
+// @return {[]*localService}
+//
+func (s *proxy) dueLocals(all bool) []*localService {
+	s.llock.Lock()
+	defer s.llock.Unlock()
+
+	now := time.Now()
+	locals := make([]*localService, 0, len(s.locals))
+	if s.stopping {
+		return locals
+	}
+
+	for _, l := range s.locals {
+		if l.held {
+			continue
+		}
+		if !all && (0 >= l.ttl || now.Before(l.next)) {
+			continue
+		}
+
+		l.next = now.Add(heartbeatInterval(l.ttl))
+		locals = append(locals, l)
+	}
+
+	return locals
+}
+
+// 定时发送带ttl的心跳，进程异常退出后节点会在s2s中过期
+//
+func (s *proxy) heartbeat() {
+	ticker := time.NewTicker(heartbeatTick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		}
+
+		for _, l := range s.dueLocals(false) {
+			err := s.registerLocal(l, false)
+			if nil != err {
+				logger.Warn("heartbeat register err", zap.Any("name", l.name), zap.Error(err))
+			}
+		}
+	}
+}
+
+// 重连s2s后重新注册所有本地服务
+//
+func (s *proxy) reregister() {
+	for _, l := range s.dueLocals(true) {
+		err := s.registerLocal(l, true)
+		if nil != err {
+			logger.Warn("reregister err", zap.Any("name", l.name), zap.Error(err))
+
+			continue
+		}
+
+		logger.Info("reregister success", zap.Any("name", l.name))
+	}
+}
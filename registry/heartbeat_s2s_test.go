@@ -0,0 +1,61 @@
+package registry
+
+import (
+	"testing"
+	"time"
+
+	"go-micro.dev/v4/registry"
+)
+
+func Test_heartbeatInterval(t *testing.T) {
+	cases := []struct {
+		ttl      time.Duration
+		interval time.Duration
+	}{
+		{30 * time.Second, 10 * time.Second},
+		{9 * time.Second, 3 * time.Second},
+		{2 * time.Second, heartbeatTick},
+		{0, heartbeatTick},
+	}
+
+	for _, c := range cases {
+		if interval := heartbeatInterval(c.ttl); interval != c.interval {
+			t.Errorf("heartbeatInterval(%v) = %v, expect %v", c.ttl, interval, c.interval)
+		}
+	}
+}
+
+func Test_dueLocals(t *testing.T) {
+	now := time.Now()
+	cases := []struct {
+		name string
+		l    *localService
+		all  bool
+		due  bool
+	}{
+		{"due", &localService{ttl: 30 * time.Second, next: now.Add(-time.Second)}, false, true},
+		{"not due", &localService{ttl: 30 * time.Second, next: now.Add(time.Second)}, false, false},
+		{"no ttl", &localService{next: now.Add(-time.Second)}, false, false},
+		{"all ignores next", &localService{ttl: 30 * time.Second, next: now.Add(time.Hour)}, true, true},
+		{"all includes no ttl", &localService{}, true, true},
+		{"held", &localService{ttl: 30 * time.Second, next: now.Add(-time.Second), held: true}, true, false},
+	}
+
+	for _, c := range cases {
+		l := c.l
+		l.name = "test.svr"
+		l.svc = &registry.Service{Name: "test.svr"}
+		s := &proxy{locals: map[string]*localService{"test": l}}
+
+		due := s.dueLocals(c.all)
+		if (1 == len(due)) != c.due {
+			t.Errorf("%s: unexpected due %v", c.name, due)
+			continue
+		}
+
+		// 返回后推迟下次心跳
+		if c.due && !l.next.After(now) {
+			t.Errorf("%s: next heartbeat not updated", c.name)
+		}
+	}
+}
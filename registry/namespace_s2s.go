@@ -0,0 +1,147 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"go-micro.dev/v4/registry"
+)
+
+type namespaceKey struct{}
+
+// 设置服务注册和发现使用的命名空间，不同命名空间的服务互相不可见
+// 没有设置时读取配置中的s2s.namespace，为空时使用旧的无命名空间协议
+//
+// @param ns
+// @return Option
+//
+func Namespace(ns string) registry.Option {
+	return func(o *registry.Options) {
+		if nil == o.Context {
+			o.Context = context.Background()
+		}
+
+		o.Context = context.WithValue(o.Context, namespaceKey{}, ns)
+	}
+}
+
+// 当前使用的命名空间
+//
+// @return string
+//
+func (s *proxy) namespace() string {
+	if nil != s.opts.Context {
+		if ns, ok := s.opts.Context.Value(namespaceKey{}).(string); ok {
+			return ns
+		}
+	}
+
+	return s2sConfig("namespace").String("")
+}
+
+// domain对应的命名空间，默认domain对应空的命名空间
+//
+// @param domain
+// @return string
+//
+func domainNamespace(domain string) string {
+	if DefaultDomain == domain {
+		return ""
+	}
+
+	return domain
+}
+
+// 命名空间对应的domain
+//
+// @param ns
+// @return string
+//
+func namespaceDomain(ns string) string {
+	if 0 == len(ns) {
+		return DefaultDomain
+	}
+
+	return ns
+}
+
+// 服务缓存的key，带上命名空间
+//
+// @param ns
+// @param name
+// @return string
+//
+func cacheKey(ns, name string) string {
+	if 0 == len(ns) {
+		return name
+	}
+
+	return ns + "/" + name
+}
+
+// 从缓存的key中解析命名空间和服务名
+//
+// @param key
+// @return {ns,name}
+//
+func splitCacheKey(key string) (string, string) {
+	idx := strings.LastIndex(key, "/")
+	if 0 > idx {
+		return "", key
+	}
+
+	return key[:idx], key[idx+1:]
+}
+
+// 带上命名空间的请求扩展字段
+//
+// @param ns
+// @param extra
+// @return map[string]string
+//
+func withNamespace(ns string, extra map[string]string) map[string]string {
+	if nil == extra {
+		extra = make(map[string]string)
+	}
+	if 0 < len(ns) {
+		extra["ns"] = ns
+	}
+
+	return extra
+}
+
+// 拼接s2s的http地址，/registry/{part}/{part}
+//
+// @param addr
+// @param parts 	每一部分会被转义
+// @return string
+//
+func (s *proxy) registryUrl(addr string, parts ...string) string {
+	scheme := "http"
+	if s.opts.Secure || nil != s.tls {
+		scheme = "https"
+	}
+
+	path := "/registry"
+	for _, p := range parts {
+		path = path + "/" + url.QueryEscape(p)
+	}
+
+	return fmt.Sprintf("%s://%s%s", scheme, addr, path)
+}
+
+// 命名空间在http路径中的部分，为空时使用旧的路径
+//
+// @param ns
+// @param parts
+// @return []string
+//
+func nsParts(ns string, parts ...string) []string {
+	if 0 == len(ns) {
+		return parts
+	}
+
+	return append([]string{ns}, parts...)
+}
@@ -0,0 +1,221 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"go-micro.dev/v4/logger"
+	"go-micro.dev/v4/registry"
+	"go.uber.org/zap"
+)
+
+// s2s推送的notify消息的Data，带上变化后服务的所有节点
+// 旧版本s2s的Data只有服务名或者为空
+type Notify struct {
+	Ns       string              `json:"ns,omitempty"`
+	Name     string              `json:"name"`
+	Services []*registry.Service `json:"services"`
+}
+
+// 合并一段时间内收到的通知，同一个服务只保留最新的通知
+type notifyQueue struct {
+	lock sync.Mutex
+	// 带节点信息的通知
+	items map[string]*Notify
+	// 只有服务名的通知，需要重新获取节点
+	names map[string]bool
+	// 需要刷新所有订阅的服务
+	full bool
+}
+
+func newNotifyQueue() *notifyQueue {
+	return &notifyQueue{
+		items: make(map[string]*Notify),
+		names: make(map[string]bool),
+	}
+}
+
+func (q *notifyQueue) push(n *Notify) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.items[cacheKey(n.Ns, n.Name)] = n
+}
+
+func (q *notifyQueue) pushName(name string) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.names[name] = true
+}
+
+func (q *notifyQueue) pushFull() {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	q.full = true
+}
+
+// 取出所有合并后的通知
+//
+// @return {items,names,full}
+//
+func (q *notifyQueue) take() ([]*Notify, []string, bool) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+
+	items := make([]*Notify, 0, len(q.items))
+	for _, n := range q.items {
+		items = append(items, n)
+	}
+	names := make([]string, 0, len(q.names))
+	for name := range q.names {
+		names = append(names, name)
+	}
+	full := q.full
+
+	q.items = make(map[string]*Notify)
+	q.names = make(map[string]bool)
+	q.full = false
+
+	return items, names, full
+}
+
+// 通知crontab刷新，不会阻塞，已经有等待处理的刷新时直接返回
+//
+func (s *proxy) wakeup() {
+	select {
+	case s.refresh <- true:
+	default:
+	}
+}
+
+// 刷新所有订阅的服务
+//
+func (s *proxy) requestRefresh() {
+	s.notifies.pushFull()
+	s.wakeup()
+}
+
+// 处理s2s推送的通知，在tcp读协程中调用，不能阻塞
+//
+// @param data 	notify消息的Data
+//
+func (s *proxy) onNotify(data string) {
+	data = strings.TrimSpace(data)
+	if 0 == len(data) {
+		s.requestRefresh()
+
+		return
+	}
+
+	if !strings.HasPrefix(data, "{") {
+		s.notifies.pushName(data)
+		s.wakeup()
+
+		return
+	}
+
+	var n Notify
+	err := json.Unmarshal([]byte(data), &n)
+	if nil != err || 0 == len(n.Name) {
+		logger.Warn("decode s2s notify err, refresh all", zap.Any("data", data), zap.Error(err))
+
+		s.requestRefresh()
+		return
+	}
+
+	s.notifies.push(&n)
+	s.wakeup()
+}
+
+// 等待合并通知的时间，读取配置中的s2s.notify_debounce，单位毫秒，默认100
+//
+// @return time.Duration
+//
+func notifyDebounce() time.Duration {
+	ms := s2sConfig("notify_debounce").Int(100)
+	if 0 > ms {
+		ms = 0
+	}
+
+	return time.Duration(ms) * time.Millisecond
+}
+
+// 更新一个服务的缓存，没有节点时删除缓存
+//
+// @param ns
+// @param name
+// @param services
+// @return {[]Result} 	节点变化的事件
+//
+func (s *proxy) updateCache(ns, name string, services []*registry.Service) []*registry.Result {
+	services = liveServices(services)
+	key := cacheKey(ns, name)
+
+	s.rwlock.Lock()
+	old := s.svrs[key]
+	if 0 == len(services) {
+		// s2s确认服务已经没有节点，删除缓存
+		delete(s.svrs, key)
+	} else {
+		s.svrs[key] = services
+	}
+	s.rwlock.Unlock()
+
+	if 0 == len(services) && 0 < len(old) {
+		logger.Info("service removed", zap.Any("name", name))
+	}
+
+	return diffServices(old, services)
+}
+
+// 只更新通知中的服务，只有服务名的通知重新获取这些服务
+//
+// @param items
+// @param names
+//
+func (s *proxy) applyNotifies(items []*Notify, names []string) {
+	ns := s.namespace()
+	results := make([]*registry.Result, 0)
+
+	for _, n := range items {
+		if n.Ns != ns || !watchNode.Has(n.Name) {
+			continue
+		}
+
+		results = append(results, s.updateCache(ns, n.Name, n.Services)...)
+	}
+
+	fetch := make([]string, 0, len(names))
+	for _, name := range names {
+		if watchNode.Has(name) {
+			fetch = append(fetch, name)
+		}
+	}
+	if 0 < len(fetch) {
+		svrs, err := s.getServices(context.Background(), strings.Join(fetch, ","))
+		if nil != err {
+			logger.Error("refresh notified services err", zap.Any("names", fetch), zap.Error(err))
+		}
+
+		for k, v := range svrs {
+			results = append(results, s.updateCache(ns, k, v)...)
+		}
+	}
+
+	if 0 == len(results) {
+		return
+	}
+
+	logger.Debug("apply s2s notify", zap.Any("items", len(items)), zap.Any("names", fetch), zap.Any("events", len(results)))
+	s.broadcast(results)
+
+	err := s.saveSnapshot()
+	if nil != err {
+		logger.Warn("save s2s snapshot err", zap.Error(err))
+	}
+}
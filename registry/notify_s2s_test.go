@@ -0,0 +1,79 @@
+package registry
+
+import (
+	"strings"
+	"testing"
+
+	"go-micro.dev/v4/registry"
+)
+
+func Test_onNotify(t *testing.T) {
+	s := &proxy{refresh: make(chan bool, 1), notifies: newNotifyQueue()}
+
+	// 通知风暴不会阻塞，同一个服务只保留最后一次
+	for i := 0; i < 100; i++ {
+		s.onNotify(`{"name":"test.svr","services":[]}`)
+	}
+	s.onNotify("other.svr")
+
+	if 1 != len(s.refresh) {
+		t.Fatal("refresh not signaled")
+	}
+
+	items, names, full := s.notifies.take()
+	if 1 != len(items) || 1 != len(names) || full {
+		t.Fatalf("unexpected notifies %v %v %v", items, names, full)
+	}
+
+	s.onNotify("")
+	if _, _, full := s.notifies.take(); !full {
+		t.Fatal("empty notify should refresh all")
+	}
+}
+
+func Test_updateCache(t *testing.T) {
+	svc := func(nodes ...string) *registry.Service {
+		s := &registry.Service{Name: "test.svr", Version: "1"}
+		for _, id := range nodes {
+			s.Nodes = append(s.Nodes, &registry.Node{Id: id, Address: id})
+		}
+		return s
+	}
+
+	cases := []struct {
+		name    string
+		old     []*registry.Service
+		cur     []*registry.Service
+		cached  bool
+		actions string
+	}{
+		{"new service", nil, []*registry.Service{svc("a")}, true, "create"},
+		// s2s确认没有节点时删除缓存
+		{"confirmed empty", []*registry.Service{svc("a")}, []*registry.Service{svc()}, false, "delete"},
+		{"nil response", []*registry.Service{svc("a")}, nil, false, "delete"},
+		{"empty version dropped", []*registry.Service{svc("a")}, []*registry.Service{svc("a"), {Name: "test.svr", Version: "2"}}, true, ""},
+		{"unknown empty", nil, []*registry.Service{svc()}, false, ""},
+	}
+
+	for _, c := range cases {
+		s := &proxy{svrs: make(map[string][]*registry.Service)}
+		if nil != c.old {
+			s.svrs["test.svr"] = c.old
+		}
+
+		actions := make([]string, 0)
+		for _, res := range s.updateCache("", "test.svr", c.cur) {
+			actions = append(actions, res.Action)
+		}
+
+		cached, ok := s.svrs["test.svr"]
+		if ok != c.cached || strings.Join(actions, ",") != c.actions {
+			t.Errorf("%s: unexpected cache %v actions %v", c.name, cached, actions)
+		}
+		for _, v := range cached {
+			if 0 == len(v.Nodes) {
+				t.Errorf("%s: empty service cached", c.name)
+			}
+		}
+	}
+}
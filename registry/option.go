@@ -1,49 +1,79 @@
 package registry
 
 import (
-	"sync"
+	"context"
 
 	"go-micro.dev/v4/registry"
 )
 
-type Deregister struct {
-	LocalSvr *registry.Service
+// 服务没有设置domain时使用的默认domain
+const DefaultDomain = "micro"
 
-	isDe bool
-	lock sync.Mutex
-}
+// 匹配所有domain
+const WildcardDomain = "*"
 
-var gDeregister *Deregister
+type domainKey struct{}
 
-func GetDeregister() *Deregister {
-	if nil == gDeregister {
-		gDeregister = &Deregister{
-			LocalSvr: nil,
-			isDe:     false,
-			lock:     sync.Mutex{},
+// 设置ListServices过滤的domain
+//
+// @param domain
+// @return ListOption
+//
+func ListDomain(domain string) registry.ListOption {
+	return func(o *registry.ListOptions) {
+		if nil == o.Context {
+			o.Context = context.Background()
 		}
+
+		o.Context = context.WithValue(o.Context, domainKey{}, domain)
+	}
+}
+
+// 获取ListOptions中设置的domain，没有设置则匹配所有
+//
+// @param o
+// @return string
+//
+func listDomain(o *registry.ListOptions) string {
+	if nil == o.Context {
+		return WildcardDomain
+	}
+
+	domain, ok := o.Context.Value(domainKey{}).(string)
+	if !ok || 0 == len(domain) {
+		return WildcardDomain
 	}
 
-	return gDeregister
+	return domain
 }
 
-// 注销调用，说明已经注销
+// 获取服务所在的domain，记录在服务的Metadata中
+//
+// @param svc
+// @return string
 //
-func (this *Deregister) De() {
-	this.lock.Lock()
-	defer this.lock.Unlock()
+func serviceDomain(svc *registry.Service) string {
+	if nil == svc || nil == svc.Metadata {
+		return DefaultDomain
+	}
+
+	if domain, ok := svc.Metadata["domain"]; ok && 0 < len(domain) {
+		return domain
+	}
 
-	this.isDe = true
-	return
+	return DefaultDomain
 }
 
-// 检查是否已经注销，主要用于注册的时候判断
+// 检查服务是否属于domain
 //
+// @param svc
+// @param domain
 // @return bool
 //
-func (this *Deregister) IsDe() bool {
-	this.lock.Lock()
-	defer this.lock.Unlock()
+func matchDomain(svc *registry.Service, domain string) bool {
+	if WildcardDomain == domain {
+		return true
+	}
 
-	return this.isDe
+	return serviceDomain(svc) == domain
 }
@@ -0,0 +1,287 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go-micro.dev/v4/logger"
+	"go.uber.org/zap"
+)
+
+const (
+	// context中没有设置deadline时的默认超时时间
+	defaultCallTimeout = 2 * time.Second
+)
+
+var (
+	ErrCallTimeout  = errors.New("s2s call timeout")
+	ErrCallCanceled = errors.New("s2s call canceled")
+)
+
+// s2s返回的错误响应
+type ResError struct {
+	Cmd  string
+	Code string
+	Msg  string
+}
+
+func (e *ResError) Error() string {
+	return fmt.Sprintf("s2s %s failed, code: %s, msg: %s", e.Cmd, e.Code, e.Msg)
+}
+
+// 检查响应码是否成功，兼容旧版本s2s没有设置Code的情况
+//
+// @param code
+// @return bool
+//
+func isSuccess(code string) bool {
+	switch code {
+	case "", CodeSuccess, "ok", "200":
+		return true
+	}
+
+	return false
+}
+
+// 等待响应的请求统计
+type PendingStats struct {
+	// 正在等待响应的请求数
+	Outstanding int64
+	// 总请求数
+	Total uint64
+	// 超时的请求数
+	Timeouts uint64
+	// 被取消的请求数
+	Canceled uint64
+	// s2s返回错误的请求数
+	Failed uint64
+}
+
+type pendingCall struct {
+	req   *StreamReq
+	ch    chan *StreamRes
+	start time.Time
+	// 最后一次写入的连接，同一个连接上只写一次
+	conn net.Conn
+	// 不为nil时每次写入前重新签名，nonce只能使用一次
+	key []byte
+}
+
+// 按Tag关联请求和响应
+type pending struct {
+	lock  sync.Mutex
+	calls map[string]*pendingCall
+
+	outstanding int64
+	total       uint64
+	timeouts    uint64
+	canceled    uint64
+	failed      uint64
+}
+
+func newPending() *pending {
+	return &pending{
+		calls: make(map[string]*pendingCall),
+	}
+}
+
+func (p *pending) add(req *StreamReq, key []byte) *pendingCall {
+	call := &pendingCall{
+		req:   req,
+		ch:    make(chan *StreamRes, 1),
+		start: time.Now(),
+		key:   key,
+	}
+
+	p.lock.Lock()
+	p.calls[req.Tag] = call
+	p.lock.Unlock()
+
+	atomic.AddInt64(&p.outstanding, 1)
+	atomic.AddUint64(&p.total, 1)
+	return call
+}
+
+func (p *pending) remove(tag string) {
+	p.lock.Lock()
+	_, ok := p.calls[tag]
+	delete(p.calls, tag)
+	p.lock.Unlock()
+
+	if ok {
+		atomic.AddInt64(&p.outstanding, -1)
+	}
+}
+
+// 请求是否还在等待响应
+//
+// @param tag
+// @return bool
+//
+func (p *pending) has(tag string) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	_, ok := p.calls[tag]
+	return ok
+}
+
+// 标记请求将在conn上写入，已经在conn上写入过时返回false
+// 不等待响应的请求总是返回true
+//
+// @param tag
+// @param conn
+// @return bool
+//
+func (p *pending) claim(tag string, conn net.Conn) bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	call, ok := p.calls[tag]
+	if !ok {
+		return true
+	}
+	if call.conn == conn {
+		return false
+	}
+
+	call.conn = conn
+	return true
+}
+
+// 将响应交给等待的请求
+//
+// @param res
+// @return bool 	没有对应的请求时返回false
+//
+func (p *pending) dispatch(res *StreamRes) bool {
+	p.lock.Lock()
+	call, ok := p.calls[res.Tag]
+	p.lock.Unlock()
+	if !ok {
+		return false
+	}
+
+	select {
+	case call.ch <- res:
+	default:
+	}
+
+	return true
+}
+
+func (p *pending) stats() PendingStats {
+	return PendingStats{
+		Outstanding: atomic.LoadInt64(&p.outstanding),
+		Total:       atomic.LoadUint64(&p.total),
+		Timeouts:    atomic.LoadUint64(&p.timeouts),
+		Canceled:    atomic.LoadUint64(&p.canceled),
+		Failed:      atomic.LoadUint64(&p.failed),
+	}
+}
+
+// 发送请求并等待响应，ctx没有deadline时使用timeout
+//
+// @param ctx
+// @param req 		请求，Tag为空时自动生成
+// @param timeout 	默认超时时间，小于等于0时使用defaultCallTimeout
+// @return {StreamRes,error}
+//
+func (this *tcpS2s) Call(ctx context.Context, req *StreamReq, timeout time.Duration) (*StreamRes, error) {
+	return this.call(ctx, req, nil, timeout)
+}
+
+// 签名后发送请求并等待响应，重发时重新签名
+//
+// @param ctx
+// @param req
+// @param key 		签名的密钥
+// @param timeout
+// @return {StreamRes,error}
+//
+func (this *tcpS2s) CallSigned(ctx context.Context, req *StreamReq, key []byte, timeout time.Duration) (*StreamRes, error) {
+	return this.call(ctx, req, key, timeout)
+}
+
+func (this *tcpS2s) call(ctx context.Context, req *StreamReq, key []byte, timeout time.Duration) (*StreamRes, error) {
+	if nil == ctx {
+		ctx = context.Background()
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		if 0 >= timeout {
+			timeout = defaultCallTimeout
+		}
+
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	if 0 == len(req.Tag) {
+		req.Tag = getRandomTag()
+	}
+
+	if nil != key {
+		SignRequest(key, req)
+	}
+
+	// 先登记再发送，避免响应比登记先到
+	call := this.pending.add(req, key)
+	defer this.pending.remove(req.Tag)
+
+	// 连接断开时在ctx的时间内重连，不会超过调用方的超时时间
+	err := this.send(ctx, req)
+	if nil != err {
+		if nil != ctx.Err() {
+			return nil, this.callDone(ctx, call)
+		}
+
+		return nil, err
+	}
+
+	select {
+	case res := <-call.ch:
+		if !isSuccess(res.Code) {
+			atomic.AddUint64(&this.pending.failed, 1)
+
+			return nil, &ResError{Cmd: req.Cmd, Code: res.Code, Msg: res.Data}
+		}
+
+		return res, nil
+
+	case <-ctx.Done():
+		return nil, this.callDone(ctx, call)
+	}
+}
+
+// ctx结束时按超时或者取消统计并返回错误
+//
+// @param ctx
+// @param call
+// @return error
+//
+func (this *tcpS2s) callDone(ctx context.Context, call *pendingCall) error {
+	req := call.req
+	delay := time.Since(call.start)
+	if context.DeadlineExceeded == ctx.Err() {
+		atomic.AddUint64(&this.pending.timeouts, 1)
+		logger.Warn("s2s call timeout!", zap.Any("cmd", req.Cmd), zap.Any("data", req.Data), zap.Any("tag", req.Tag), zap.Any("delay", delay))
+
+		return fmt.Errorf("%w, cmd: %s, data: %s, delay: %v", ErrCallTimeout, req.Cmd, req.Data, delay)
+	}
+
+	atomic.AddUint64(&this.pending.canceled, 1)
+	return fmt.Errorf("%w, cmd: %s, data: %s", ErrCallCanceled, req.Cmd, req.Data)
+}
+
+// 等待响应的请求统计
+//
+// @return PendingStats
+//
+func (this *tcpS2s) Stats() PendingStats {
+	return this.pending.stats()
+}
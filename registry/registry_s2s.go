@@ -3,19 +3,19 @@ package registry
 
 import (
 	"bytes"
-	"encoding/gob"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
-	"net/url"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/heegspace/appcom"
 	"go-micro.dev/v4/cmd"
 	"go-micro.dev/v4/logger"
 	"go-micro.dev/v4/registry"
@@ -25,62 +25,39 @@ import (
 type proxy struct {
 	opts registry.Options
 
+	// 不使用tls时tls为nil，client为http.DefaultClient
+	tls    *s2sTLS
+	client *http.Client
+
 	rwlock sync.RWMutex
 	svrs   map[string][]*registry.Service
 
-	refresh chan bool
-	upch    map[string]chan string
-	chlock  sync.RWMutex
-
-	first bool
-}
-
-type watchType = []string
-type WatchNode struct {
-	watchType
+	// 有新的通知需要处理
+	refresh  chan bool
+	notifies *notifyQueue
 
-	lock sync.RWMutex
-}
+	watchers map[string]*watcher
+	wlock    sync.RWMutex
 
-func (w *WatchNode) Add(obj string) {
-	w.lock.Lock()
-	defer w.lock.Unlock()
+	locals map[string]*localService
+	llock  sync.Mutex
+	// 进程退出中，不再注册任何服务
+	stopping bool
+	// 正在下线的服务，key为空表示所有服务
+	drains map[string]bool
 
-	for _, v := range w.watchType {
-		if v == obj {
-			return
-		}
-	}
+	// 快照保存的时间，stale为true表示缓存中还是快照的数据
+	snapshotAt time.Time
+	stale      bool
 
-	w.watchType = append(w.watchType, obj)
+	// 已经发送过首次注册，心跳、重连和下线等会并发注册，使用原子操作
+	first int32
 }
 
-func (w *WatchNode) Nodes() []string {
-	return w.watchType
-}
-
-var watchNode WatchNode
-
 func init() {
 	cmd.DefaultRegistries["proxy"] = NewRegistry
 }
 
-// 设置要监听的节点连接信息
-//
-// @param nodes 配置中的nodes项
-//
-func SetWatchNode(nodes []string) {
-	if 0 == len(nodes) {
-		return
-	}
-
-	for _, v := range nodes {
-		watchNode.Add(v)
-	}
-
-	return
-}
-
 func configure(s *proxy, opts ...registry.Option) error {
 	for _, o := range opts {
 		o(&s.opts)
@@ -96,6 +73,12 @@ func configure(s *proxy, opts ...registry.Option) error {
 	}
 
 	registry.Addrs(addrs...)(&s.opts)
+	setProvider(s.opts)
+
+	s.tls = newS2sTLS(s.opts)
+	s.client = newHttpClient(s.tls)
+	TcpS2s().setTLS(s.tls)
+
 	return nil
 }
 
@@ -104,23 +87,39 @@ var gs *proxy
 func newRegistry(opts ...registry.Option) registry.Registry {
 	if nil == gs {
 		gs = &proxy{
-			opts:    registry.Options{},
-			rwlock:  sync.RWMutex{},
-			svrs:    make(map[string][]*registry.Service),
-			upch:    make(map[string]chan string),
-			refresh: make(chan bool, 2),
-			chlock:  sync.RWMutex{},
-			first:   false,
+			opts:     registry.Options{},
+			rwlock:   sync.RWMutex{},
+			svrs:     make(map[string][]*registry.Service),
+			refresh:  make(chan bool, 1),
+			notifies: newNotifyQueue(),
+			watchers: make(map[string]*watcher),
+			wlock:    sync.RWMutex{},
+			locals:   make(map[string]*localService),
+			drains:   make(map[string]bool),
+			llock:    sync.Mutex{},
 		}
 
-		if TcpS2s().enable() {
-			TcpS2s().Connect()
-			gs.onStart()
+		configure(gs, opts...)
+
+		// s2s不可用时使用快照中的节点
+		err := gs.loadSnapshot()
+		if nil != err {
+			logger.Warn("load s2s snapshot err", zap.Error(err))
+		}
+		if gs.stale {
+			// 尽快用s2s的数据替换快照
+			gs.requestRefresh()
 		}
 
-		go gs.crontab()
+		// 在后台连接s2s并负责重连，s2s不可用时不阻塞服务创建
+		gs.onStart()
 
-		configure(gs, opts...)
+		// 提前开始采集系统信息，注册时不需要等待
+		sysinfoCollector()
+
+		go gs.crontab()
+		go gs.heartbeat()
+		go gs.pushSysinfo()
 	}
 
 	return gs
@@ -141,16 +140,65 @@ func (s *proxy) Register(service *registry.Service, opts ...registry.RegisterOpt
 		return err
 	}
 
-	if GetDeregister().IsDe() {
+	// 进程退出中或者服务暂时离开时不注册
+	if !s.allowRegister(service) {
 		return nil
 	}
 
+	var ro registry.RegisterOptions
+	for _, o := range opts {
+		o(&ro)
+	}
+
 	if nil == service.Metadata {
 		service.Metadata = make(map[string]string)
 	}
-	service.Metadata["sysinfo"] = getsysinfo()
+	if ns := s.namespace(); 0 < len(ns) {
+		service.Metadata["domain"] = namespaceDomain(ns)
+	}
 
-	b, err := json.Marshal(service)
+	// 节点带上机房信息，用于按zone过滤
+	zone := s2sConfig("zone").String("")
+	for _, n := range service.Nodes {
+		if 0 == len(zone) {
+			continue
+		}
+
+		if nil == n.Metadata {
+			n.Metadata = make(map[string]string)
+		}
+		if _, ok := n.Metadata["zone"]; !ok {
+			n.Metadata["zone"] = zone
+		}
+	}
+
+	// 已经注册过的服务和心跳、Leave互斥发送
+	if nil != s.findLocal(service) {
+		return s.registerLocal(s.track(service, ro.TTL), false)
+	}
+
+	err := s.register(service, ro.TTL, false)
+	if nil != err {
+		return err
+	}
+
+	s.track(service, ro.TTL)
+	return nil
+}
+
+// 发送注册信息到s2s，ttl大于0时s2s在ttl内没有收到心跳会删除节点
+//
+// @param service 	服务信息
+// @param ttl 		节点过期时间
+// @param first 	是否为连接上的首次注册，重连后需要重新标记
+// @return error
+//
+func (s *proxy) register(service *registry.Service, ttl time.Duration, first bool) error {
+	// 每次注册和心跳都带上最新的系统信息和节点状态
+	svc := withSysinfo(service)
+	s.withState(svc)
+
+	b, err := json.Marshal(svc)
 	if err != nil {
 		return err
 	}
@@ -160,41 +208,36 @@ func (s *proxy) Register(service *registry.Service, opts ...registry.RegisterOpt
 		req.Cmd = "update"
 		req.Data = string(b)
 		req.Tag = getRandomTag()
-		req.Extra = make(map[string]string)
-		if !s.first {
+		req.Extra = withNamespace(s.namespace(), nil)
+		if s.markFirst(first) {
 			req.Extra["first"] = "first"
-
-			s.first = true
 		}
-
-		buf := &bytes.Buffer{}
-		enc := gob.NewEncoder(buf)
-		err := enc.Encode(req)
-		if nil != err {
-			return err
+		if 0 < ttl {
+			req.Extra["ttl"] = strconv.FormatInt(int64(ttl/time.Second), 10)
 		}
 
-	register:
-		_, err = appcom.WriteToConnections(TcpS2s().GetConn(), buf.Bytes())
-		if nil != err {
-			logger.Error("Register err", zap.Error(err))
-			TcpS2s().Connect()
-			req.Extra["first"] = "first"
-			goto register
-		}
-
-		GetDeregister().LocalSvr = service
-		return nil
+		// 等待s2s的响应，签名错误等失败会返回给调用方
+		_, err := TcpS2s().CallSigned(context.Background(), &req, s.signKey(service.Name), 0)
+		return err
 	}
 
 	var gerr error
 	for _, addr := range s.opts.Addrs {
-		scheme := "http"
-		if s.opts.Secure {
-			scheme = "https"
+		url := s.registryUrl(addr, nsParts(s.namespace())...)
+		if 0 < ttl {
+			url = fmt.Sprintf("%s?ttl=%d", url, int64(ttl/time.Second))
+		}
+		req, err := http.NewRequest("POST", url, bytes.NewReader(b))
+		if err != nil {
+			gerr = err
+			continue
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if key := s.signKey(service.Name); nil != key {
+			SignHttp(key, req, "update", s.namespace(), b)
 		}
-		url := fmt.Sprintf("%s://%s/registry", scheme, addr)
-		rsp, err := http.Post(url, "application/json", bytes.NewReader(b))
+
+		rsp, err := s.client.Do(req)
 		if err != nil {
 			gerr = err
 			continue
@@ -211,14 +254,44 @@ func (s *proxy) Register(service *registry.Service, opts ...registry.RegisterOpt
 		io.Copy(ioutil.Discard, rsp.Body)
 		rsp.Body.Close()
 
-		GetDeregister().LocalSvr = service
 		return nil
 	}
 
 	return gerr
 }
 
+// 是否需要带上first标记，并发注册时只有一个请求会带上
+//
+// @param first 	重连后的首次注册，总是带上
+// @return bool
+//
+func (s *proxy) markFirst(first bool) bool {
+	if first {
+		atomic.StoreInt32(&s.first, 1)
+
+		return true
+	}
+
+	return atomic.CompareAndSwapInt32(&s.first, 0, 1)
+}
+
 func (s *proxy) Deregister(service *registry.Service, opts ...registry.DeregisterOption) error {
+	err := s.deregister(service)
+	if nil != err {
+		return err
+	}
+
+	// 注销后可以重新注册
+	s.untrack(service)
+	return nil
+}
+
+// 发送注销信息到s2s
+//
+// @param service
+// @return error
+//
+func (s *proxy) deregister(service *registry.Service) error {
 	b, err := json.Marshal(service)
 	if err != nil {
 		return err
@@ -230,42 +303,27 @@ func (s *proxy) Deregister(service *registry.Service, opts ...registry.Deregiste
 		req.Cmd = "delete"
 		req.Data = string(b)
 		req.Tag = getRandomTag()
-		buf := &bytes.Buffer{}
-		enc := gob.NewEncoder(buf)
-		err := enc.Encode(req)
-		if nil != err {
-			return err
-		}
-
-	register:
-		_, err = appcom.WriteToConnections(TcpS2s().GetConn(), buf.Bytes())
-		if nil != err {
-			logger.Error("Deregister WriteToConnections err", zap.Error(err))
+		req.Extra = withNamespace(s.namespace(), nil)
 
-			TcpS2s().Connect()
-			goto register
-		}
-
-		GetDeregister().De()
-		return nil
+		_, err := TcpS2s().CallSigned(context.Background(), &req, s.signKey(service.Name), 0)
+		return err
 	}
 
 	// http
 	var gerr error
 	for _, addr := range s.opts.Addrs {
-		scheme := "http"
-		if s.opts.Secure {
-			scheme = "https"
-		}
-		url := fmt.Sprintf("%s://%s/registry", scheme, addr)
+		url := s.registryUrl(addr, nsParts(s.namespace())...)
 
 		req, err := http.NewRequest("DELETE", url, bytes.NewReader(b))
 		if err != nil {
 			gerr = err
 			continue
 		}
+		if key := s.signKey(service.Name); nil != key {
+			SignHttp(key, req, "delete", s.namespace(), b)
+		}
 
-		rsp, err := http.DefaultClient.Do(req)
+		rsp, err := s.client.Do(req)
 		if err != nil {
 			gerr = err
 			continue
@@ -284,8 +342,6 @@ func (s *proxy) Deregister(service *registry.Service, opts ...registry.Deregiste
 		io.Copy(ioutil.Discard, rsp.Body)
 		rsp.Body.Close()
 
-		GetDeregister().De()
-
 		return nil
 	}
 
@@ -302,18 +358,30 @@ func (s *proxy) GetService(service string, opts ...registry.GetOption) ([]*regis
 		return nil, errors.New("Service name is nil")
 	}
 
+	var gopts registry.GetOptions
+	for _, o := range opts {
+		o(&gopts)
+	}
+
 	watchNode.Add(service)
+	filters := append(s.defaultFilters(), contextFilters(gopts.Context)...)
+
+	key := cacheKey(s.namespace(), service)
 
 	s.rwlock.RLock()
 	defer s.rwlock.RUnlock()
 
-	if _, ok := s.svrs[service]; ok {
-		item := make([]*registry.Service, 0)
-		for _, v := range s.svrs[service] {
-			var svr registry.Service
+	if _, ok := s.svrs[key]; ok {
+		// 返回副本，调用方和过滤条件修改节点不会影响缓存
+		item := copyServices(s.svrs[key])
 
-			svr = *v
-			item = append(item, &svr)
+		if s.stale {
+			logger.Warn("GetService node from snapshot", zap.Any("name", service), zap.Any("age", time.Since(s.snapshotAt)))
+		}
+
+		item = applyFilters(item, filters)
+		if 0 == len(item) {
+			return nil, registry.ErrNotFound
 		}
 
 		logger.Debug("GetService node exists", zap.Any("name", service), zap.Any("nodes", toJson(item)))
@@ -321,16 +389,101 @@ func (s *proxy) GetService(service string, opts ...registry.GetOption) ([]*regis
 	}
 
 	logger.Debug("GetService node not exists", zap.Any("name", service))
-	return s.getService(service)
+	services, err := s.getService(gopts.Context, service)
+	if nil != err {
+		return nil, err
+	}
+
+	services = applyFilters(liveServices(services), filters)
+	if 0 == len(services) {
+		return nil, registry.ErrNotFound
+	}
+
+	return services, nil
 }
 
+// 去掉没有节点的服务版本
+//
+// @param services
+// @return {[]Service}
+//
+func liveServices(services []*registry.Service) []*registry.Service {
+	live := make([]*registry.Service, 0, len(services))
+	for _, v := range services {
+		if nil == v || 0 == len(v.Nodes) {
+			continue
+		}
+
+		live = append(live, v)
+	}
+
+	return live
+}
+
+// 获取s2s中注册的所有服务，s2s不可用时使用内存中的缓存
+//
+// @param opts 	ListDomain可以按domain过滤
+// @return {[]Service,error}
+//
 func (s *proxy) ListServices(opts ...registry.ListOption) ([]*registry.Service, error) {
-	var gerr error
-	logger.Info("ListServices")
+	var lo registry.ListOptions
+	for _, o := range opts {
+		o(&lo)
+	}
+	domain := listDomain(&lo)
 
-	return nil, gerr
+	// 指定domain时查询对应的命名空间
+	ns := s.namespace()
+	if WildcardDomain != domain {
+		ns = domainNamespace(domain)
+	}
+
+	services, err := s.listServices(lo.Context, ns)
+	if nil != err {
+		logger.Warn("ListServices from s2s err, use cache", zap.Error(err))
+
+		services = s.cacheServices(ns)
+	}
+
+	result := make([]*registry.Service, 0, len(services))
+	for _, v := range services {
+		if !matchDomain(v, domain) {
+			continue
+		}
+
+		result = append(result, v)
+	}
+
+	logger.Debug("ListServices", zap.Any("domain", domain), zap.Any("size", len(result)))
+	return result, nil
+}
+
+// 获取内存中缓存的命名空间下的所有服务
+//
+// @param ns
+// @return {[]Service}
+//
+func (s *proxy) cacheServices(ns string) []*registry.Service {
+	s.rwlock.RLock()
+	defer s.rwlock.RUnlock()
+
+	services := make([]*registry.Service, 0, len(s.svrs))
+	for k, v := range s.svrs {
+		if kns, _ := splitCacheKey(k); kns != ns {
+			continue
+		}
+
+		services = append(services, copyServices(v)...)
+	}
+
+	return services
 }
 
+// 监听服务节点变化，节点变化来源于s2s的推送和定时刷新
+//
+// @param opts 	监听选项，wo.Service为空时监听所有服务
+// @return {Watcher,error}
+//
 func (s *proxy) Watch(opts ...registry.WatchOption) (registry.Watcher, error) {
 	var wo registry.WatchOptions
 	for _, o := range opts {
@@ -338,7 +491,26 @@ func (s *proxy) Watch(opts ...registry.WatchOption) (registry.Watcher, error) {
 	}
 	logger.Info("Watch, Service: ", wo.Service)
 
-	return newWatcher("")
+	// watcher停止前不会被淘汰
+	if 0 < len(wo.Service) {
+		watchNode.Subscribe(wo.Service)
+	}
+
+	w := newWatcher(getRandomTag(), wo, func(id string) {
+		s.wlock.Lock()
+		delete(s.watchers, id)
+		s.wlock.Unlock()
+
+		if 0 < len(wo.Service) {
+			watchNode.Unsubscribe(wo.Service)
+		}
+	})
+
+	s.wlock.Lock()
+	s.watchers[w.id] = w
+	s.wlock.Unlock()
+
+	return w, nil
 }
 
 func (s *proxy) String() string {
@@ -350,7 +522,7 @@ func (s *proxy) String() string {
 // @param service 	服务名
 // @return {[]Service}
 //
-func (s *proxy) getService(service string) ([]*registry.Service, error) {
+func (s *proxy) getService(ctx context.Context, service string) ([]*registry.Service, error) {
 	if 0 == len(service) {
 		return nil, errors.New("Service name is nil")
 	}
@@ -362,52 +534,92 @@ func (s *proxy) getService(service string) ([]*registry.Service, error) {
 		req.Cmd = "get"
 		req.Data = service
 		req.Tag = getRandomTag()
-		buf := &bytes.Buffer{}
-		enc := gob.NewEncoder(buf)
-		err := enc.Encode(req)
+		req.Extra = withNamespace(s.namespace(), nil)
+		res, err := TcpS2s().Call(ctx, &req, s.opts.Timeout)
 		if nil != err {
+			logger.Error("getService call err", zap.Any("s2sname", service), zap.Error(err))
+
 			return nil, err
 		}
 
-	register:
-		_, err = appcom.WriteToConnections(TcpS2s().GetConn(), buf.Bytes())
-		if nil != err {
-			logger.Error("getService WriteToConnections err", zap.Error(err))
-
-			TcpS2s().Connect()
-			goto register
-		}
-
-		// wait response, timeout 400ms
-		result := ""
-		s.chlock.Lock()
-		s.upch[req.Tag] = make(chan string, 1)
-		s.chlock.Unlock()
-		defer func() {
-			s.chlock.Lock()
-			close(s.upch[req.Tag])
-			delete(s.upch, req.Tag)
-			s.chlock.Unlock()
-		}()
-		select {
-		case msg, ok := <-s.upch[req.Tag]:
-			if ok {
-				result = msg
+		if len(res.Data) == 0 {
+			logger.Error("getService wait response return empty!")
+
+			return nil, errors.New("Didn't node info")
+		}
+
+		if err := json.Unmarshal([]byte(res.Data), &services); err != nil {
+			logger.Error("getService Unmarshal err!", zap.Any("result", res.Data), zap.Error(err))
+
+			return nil, err
+		}
+
+		return services, nil
+	}
+
+	// http
+	var gerr error
+	for _, addr := range s.opts.Addrs {
+		url := s.registryUrl(addr, nsParts(s.namespace(), service)...)
+		rsp, err := s.client.Get(url)
+		if err != nil {
+			gerr = err
+			continue
+		}
+
+		if rsp.StatusCode != 200 {
+			b, err := ioutil.ReadAll(rsp.Body)
+			if err != nil {
+				return nil, err
 			}
-		case <-time.After(time.Second * time.Duration(2)):
-			logger.Error("getService wait response timeout!", zap.Any("s2sname", service), zap.Any("req", req))
+			rsp.Body.Close()
+			gerr = errors.New(string(b))
+			continue
+		}
 
-			return nil, errors.New("getService " + service + " timeout!")
+		b, err := ioutil.ReadAll(rsp.Body)
+		if err != nil {
+			gerr = err
+			continue
 		}
+		rsp.Body.Close()
 
-		if len(result) == 0 {
-			logger.Error("getService wait response return empty!")
+		if err := json.Unmarshal(b, &services); err != nil {
+			gerr = err
+			continue
+		}
 
-			return nil, errors.New("Didn't node info")
+		return services, nil
+	}
+
+	return nil, gerr
+}
+
+// 从s2s获取所有注册的服务
+//
+// @return {[]Service,error}
+//
+func (s *proxy) listServices(ctx context.Context, ns string) ([]*registry.Service, error) {
+	// tcp
+	var services []*registry.Service
+	if TcpS2s().enable() {
+		var req StreamReq
+		req.Cmd = "list"
+		req.Tag = getRandomTag()
+		req.Extra = withNamespace(ns, nil)
+		res, err := TcpS2s().Call(ctx, &req, s.opts.Timeout)
+		if nil != err {
+			logger.Error("listServices call err", zap.Error(err))
+
+			return nil, err
+		}
+
+		if len(res.Data) == 0 {
+			return services, nil
 		}
 
-		if err := json.Unmarshal([]byte(result), &services); err != nil {
-			logger.Error("getService Unmarshal err!", zap.Any("result", result), zap.Error(err))
+		if err := json.Unmarshal([]byte(res.Data), &services); err != nil {
+			logger.Error("listServices Unmarshal err!", zap.Any("result", res.Data), zap.Error(err))
 
 			return nil, err
 		}
@@ -418,13 +630,11 @@ func (s *proxy) getService(service string) ([]*registry.Service, error) {
 	// http
 	var gerr error
 	for _, addr := range s.opts.Addrs {
-		scheme := "http"
-		if s.opts.Secure {
-			scheme = "https"
+		url := s.registryUrl(addr)
+		if 0 < len(ns) {
+			url = s.registryUrl(addr, ns, "")
 		}
-
-		url := fmt.Sprintf("%s://%s/registry/%s", scheme, addr, url.QueryEscape(service))
-		rsp, err := http.Get(url)
+		rsp, err := s.client.Get(url)
 		if err != nil {
 			gerr = err
 			continue
@@ -463,13 +673,11 @@ func (s *proxy) getService(service string) ([]*registry.Service, error) {
 // @param s2sname 	服务名
 // @return {[]Service}
 //
-func (s *proxy) getServices(s2sname string) (map[string][]*registry.Service, error) {
+func (s *proxy) getServices(ctx context.Context, s2sname string) (map[string][]*registry.Service, error) {
 	if 0 == len(s2sname) {
 		return nil, errors.New("Service name is nil")
 	}
 
-	startAt := time.Now().UnixNano()
-
 	// tcp
 	var services map[string][]*registry.Service
 	services = make(map[string][]*registry.Service)
@@ -478,46 +686,15 @@ func (s *proxy) getServices(s2sname string) (map[string][]*registry.Service, err
 		req.Cmd = "get"
 		req.Data = s2sname
 		req.Tag = getRandomTag()
-		buf := &bytes.Buffer{}
-		enc := gob.NewEncoder(buf)
-		err := enc.Encode(req)
+		req.Extra = withNamespace(s.namespace(), nil)
+		res, err := TcpS2s().Call(ctx, &req, s.opts.Timeout)
 		if nil != err {
-			return nil, err
-		}
+			logger.Warn("getServices call err", zap.Any("s2sname", s2sname), zap.Error(err))
 
-	register:
-		_, err = appcom.WriteToConnections(TcpS2s().GetConn(), buf.Bytes())
-		if nil != err {
-			logger.Error("getServices WriteToConnections  err", zap.Error(err))
-
-			TcpS2s().Connect()
-			goto register
-		}
-
-		// wait response, timeout 400ms
-		result := ""
-		s.chlock.Lock()
-		s.upch[req.Tag] = make(chan string, 1)
-		s.chlock.Unlock()
-		defer func() {
-			s.chlock.Lock()
-			close(s.upch[req.Tag])
-			delete(s.upch, req.Tag)
-			s.chlock.Unlock()
-		}()
-		select {
-		case msg, ok := <-s.upch[req.Tag]:
-			if ok {
-				result = msg
-			}
-		case <-time.After(time.Millisecond * time.Duration(400)):
-			endAt := time.Now().UnixNano()
-			logger.Warn("getService wait response timeout!", zap.Any("s2sname", s2sname), zap.Any("tag", req.Tag), zap.Any("delay", endAt-startAt))
-
-			return nil, errors.New("getServices " + s2sname + " timeout!")
+			return nil, err
 		}
 
-		if len(result) == 0 {
+		if len(res.Data) == 0 {
 			logger.Error("getService wait response return empty!")
 
 			return nil, errors.New("getServices Didn't node info")
@@ -526,16 +703,16 @@ func (s *proxy) getServices(s2sname string) (map[string][]*registry.Service, err
 		svrs := strings.Split(s2sname, ",")
 		if 1 == len(svrs) {
 			var serv []*registry.Service
-			if err = json.Unmarshal([]byte(result), &serv); err != nil {
-				logger.Error("getServices Unmarshal err!", zap.Any("result", result), zap.Error(err))
+			if err = json.Unmarshal([]byte(res.Data), &serv); err != nil {
+				logger.Error("getServices Unmarshal err!", zap.Any("result", res.Data), zap.Error(err))
 
 				return nil, err
 			}
 
 			services[s2sname] = serv
 		} else {
-			if err = json.Unmarshal([]byte(result), &services); err != nil {
-				logger.Error("getServices Unmarshal err!", zap.Any("result", result), zap.Error(err))
+			if err = json.Unmarshal([]byte(res.Data), &services); err != nil {
+				logger.Error("getServices Unmarshal err!", zap.Any("result", res.Data), zap.Error(err))
 
 				return nil, err
 			}
@@ -548,13 +725,8 @@ func (s *proxy) getServices(s2sname string) (map[string][]*registry.Service, err
 	// http
 	var gerr error
 	for _, addr := range s.opts.Addrs {
-		scheme := "http"
-		if s.opts.Secure {
-			scheme = "https"
-		}
-
-		url := fmt.Sprintf("%s://%s/registry/%s", scheme, addr, url.QueryEscape(s2sname))
-		rsp, err := http.Get(url)
+		url := s.registryUrl(addr, nsParts(s.namespace(), s2sname)...)
+		rsp, err := s.client.Get(url)
 		if err != nil {
 			gerr = err
 			continue
@@ -603,27 +775,46 @@ func (s *proxy) getServices(s2sname string) (map[string][]*registry.Service, err
 //
 func (s *proxy) crontab() {
 	fn := func() {
-		logger.Debug("crontab update", zap.Any("watchNode", watchNode.watchType), zap.Any("size", len(watchNode.watchType)))
+		// 先淘汰长时间没有使用的服务
+		s.evictWatch()
+
+		nodes := watchNode.Nodes()
+		logger.Debug("crontab update", zap.Any("watchNode", nodes), zap.Any("size", len(nodes)))
 
-		if 0 == len(watchNode.watchType) {
+		if 0 == len(nodes) {
 			return
 		}
 
-		names := strings.Join(watchNode.watchType, ",")
-		svrs, err := s.getServices(names)
+		names := strings.Join(nodes, ",")
+		svrs, err := s.getServices(context.Background(), names)
 		if nil != err {
 			logger.Error("Refresh getService err ", err)
 
 			return
 		}
+		ns := s.namespace()
+		results := make([]*registry.Result, 0)
 		for k, v := range svrs {
-			if 0 == len(v) {
-				continue
+			results = append(results, s.updateCache(ns, k, v)...)
+		}
+
+		// 响应中没有的服务视为查询失败，保留缓存
+		for _, k := range nodes {
+			if _, ok := svrs[k]; !ok {
+				logger.Warn("crontab service missing in response, keep cache", zap.Any("name", k))
 			}
+		}
 
-			s.rwlock.Lock()
-			s.svrs[k] = v
-			s.rwlock.Unlock()
+		s.rwlock.Lock()
+		s.stale = false
+		s.rwlock.Unlock()
+
+		// 通知watcher节点变化
+		s.broadcast(results)
+
+		err = s.saveSnapshot()
+		if nil != err {
+			logger.Warn("save s2s snapshot err", zap.Error(err))
 		}
 	}
 
@@ -634,12 +825,25 @@ func (s *proxy) crontab() {
 
 	// 10s定时刷新订阅的服务信息
 	ticker := time.NewTicker(time.Second * time.Duration(timer))
+	debounce := notifyDebounce()
 	for {
 		select {
 		case <-ticker.C:
 			fn()
 		case <-s.refresh:
-			fn()
+			// 等待一段时间，合并这段时间内的通知
+			if 0 < debounce {
+				time.Sleep(debounce)
+			}
+
+			items, names, full := s.notifies.take()
+			if full {
+				fn()
+
+				continue
+			}
+
+			s.applyNotifies(items, names)
 		}
 	}
 }
@@ -0,0 +1,196 @@
+package registry
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"go-micro.dev/v4/registry"
+)
+
+func Test_ListServices(t *testing.T) {
+	remote := []*registry.Service{
+		{Name: "a.svr", Version: "1"},
+		{Name: "b.svr", Version: "1", Metadata: map[string]string{"domain": "other"}},
+	}
+	down := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("s2s down"))
+			return
+		}
+
+		json.NewEncoder(w).Encode(remote)
+	}))
+	defer server.Close()
+
+	s := &proxy{
+		opts:   registry.Options{Addrs: []string{strings.TrimPrefix(server.URL, "http://")}},
+		client: http.DefaultClient,
+		svrs: map[string][]*registry.Service{
+			"cache.svr": {{Name: "cache.svr", Version: "1"}},
+		},
+	}
+
+	cases := []struct {
+		name   string
+		down   bool
+		opts   []registry.ListOption
+		expect []string
+	}{
+		{"all domains", false, nil, []string{"a.svr", "b.svr"}},
+		{"default domain", false, []registry.ListOption{ListDomain(DefaultDomain)}, []string{"a.svr"}},
+		{"s2s down use cache", true, nil, []string{"cache.svr"}},
+		{"s2s down other domain", true, []registry.ListOption{ListDomain("other")}, []string{}},
+	}
+
+	for _, c := range cases {
+		down = c.down
+		services, err := s.ListServices(c.opts...)
+		if nil != err {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+
+		names := make([]string, 0, len(services))
+		for _, v := range services {
+			names = append(names, v.Name)
+		}
+		if strings.Join(names, ",") != strings.Join(c.expect, ",") {
+			t.Errorf("%s: unexpected services %v", c.name, names)
+		}
+	}
+}
+
+func Test_registerFirst(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	var lock sync.Mutex
+	firsts := 0
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if nil != err {
+				return
+			}
+
+			go ReadFrames(conn, func(conn net.Conn, data []byte) error {
+				req, version, err := DecodeReq(data)
+				if nil != err {
+					return err
+				}
+				if "first" == req.Extra["first"] {
+					lock.Lock()
+					firsts++
+					lock.Unlock()
+				}
+
+				data, err = EncodeRes(version, &StreamRes{Cmd: req.Cmd, Code: CodeSuccess, Tag: req.Tag})
+				if nil != err {
+					return err
+				}
+
+				return WriteFrame(conn, data)
+			}, func(conn net.Conn) {})
+		}
+	}()
+
+	cli := &tcpS2s{
+		endpoints: parseEndpoints(ln.Addr().String()),
+		pending:   newPending(),
+		backoff:   DefaultBackoff,
+	}
+	if err := cli.Connect(); nil != err {
+		t.Fatal(err)
+	}
+	defer cli.GetConn().Close()
+	go ReadFrames(cli.GetConn(), func(conn net.Conn, data []byte) error {
+		res, _, err := DecodeRes(data)
+		if nil == err {
+			cli.pending.dispatch(res)
+		}
+
+		return err
+	}, func(conn net.Conn) {})
+
+	TcpS2s()
+	old := g_s2sCli
+	g_s2sCli = cli
+	defer func() { g_s2sCli = old }()
+
+	// 心跳、重连后的重新注册和下线会并发注册，只有一个请求带上first
+	s := &proxy{}
+	svc := &registry.Service{Name: "test.svr", Version: "1", Nodes: []*registry.Node{{Id: "n1", Address: "127.0.0.1:9000"}}}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if err := s.register(svc, 0, false); nil != err {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// 重连后的首次注册总是带上first
+	if err := s.register(svc, 0, true); nil != err {
+		t.Fatal(err)
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	if 2 != firsts {
+		t.Fatalf("unexpected first registers %d", firsts)
+	}
+}
+
+func Test_GetServiceCopy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	cached := &registry.Service{Name: "a.svr", Version: "1", Nodes: []*registry.Node{{Id: "n1", Metadata: map[string]string{"zone": "sh"}}}}
+	s := &proxy{
+		opts:     registry.Options{Addrs: []string{strings.TrimPrefix(server.URL, "http://")}},
+		client:   http.DefaultClient,
+		svrs:     map[string][]*registry.Service{"a.svr": {cached}},
+		watchers: make(map[string]*watcher),
+	}
+	w := newWatcher("w1", registry.WatchOptions{}, func(id string) {})
+	s.watchers[w.id] = w
+
+	// 修改GetService、ListServices返回的服务和watch事件不影响缓存
+	services, err := s.GetService("a.svr")
+	if nil != err {
+		t.Fatal(err)
+	}
+	services[0].Nodes[0].Id = "get"
+	services[0].Nodes[0].Metadata["zone"] = "bj"
+	services[0].Nodes = append(services[0].Nodes, &registry.Node{Id: "append"})
+
+	all, _ := s.ListServices()
+	all[0].Nodes[0].Id = "list"
+
+	s.broadcast([]*registry.Result{{Action: "update", Service: cached}})
+	res, err := w.Next()
+	if nil != err {
+		t.Fatal(err)
+	}
+	res.Service.Nodes[0].Id = "watch"
+
+	if 1 != len(cached.Nodes) || "n1" != cached.Nodes[0].Id || "sh" != cached.Nodes[0].Metadata["zone"] {
+		t.Fatalf("cache modified by caller %v %v", cached.Nodes[0], cached.Nodes[0].Metadata)
+	}
+}
@@ -0,0 +1,131 @@
+package s2sd
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go-micro.dev/v4/logger"
+	"go-micro.dev/v4/registry"
+	"go.uber.org/zap"
+)
+
+// http协议的处理器
+// POST /registry[/{ns}] 注册，DELETE /registry[/{ns}] 注销
+// GET /registry 获取所有服务，GET /registry/{name[,name]} 获取服务节点
+// GET /registry/{ns}/ 获取命名空间下的所有服务，GET /registry/{ns}/{name[,name]} 获取命名空间下的服务节点
+//
+// @return http.Handler
+//
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/registry", s.handleRegistry)
+	mux.HandleFunc("/registry/", s.handleRegistry)
+
+	return mux
+}
+
+func (s *Server) handleRegistry(w http.ResponseWriter, r *http.Request) {
+	ns, names := "", ""
+	rest := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/registry"), "/")
+	parts := strings.SplitN(rest, "/", 2)
+	switch {
+	case 2 == len(parts):
+		ns, names = parts[0], parts[1]
+	case "GET" == r.Method:
+		names = parts[0]
+	default:
+		ns = parts[0]
+	}
+
+	switch r.Method {
+	case "GET":
+		if 0 == len(names) {
+			writeJson(w, s.ListServices(ns))
+
+			return
+		}
+
+		writeJson(w, s.getServices(ns, names, false))
+
+	case "POST", "DELETE":
+		b, err := ioutil.ReadAll(r.Body)
+		if nil != err {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+
+			return
+		}
+
+		var svc registry.Service
+		if err := json.Unmarshal(b, &svc); nil != err {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+
+		if nil != s.opts.Verifier {
+			cmd := "update"
+			if "DELETE" == r.Method {
+				cmd = "delete"
+			}
+
+			if err := s.opts.Verifier.VerifyHttp(r, cmd, ns, b); nil != err {
+				logger.Warn("s2sd verify sign err", zap.Any("cmd", cmd), zap.Error(err))
+
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+		}
+
+		if "POST" == r.Method {
+			ttl, _ := strconv.ParseInt(r.URL.Query().Get("ttl"), 10, 64)
+			s.Register(ns, &svc, time.Duration(ttl)*time.Second)
+		} else {
+			s.Deregister(ns, &svc)
+		}
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// 批量获取服务，多个服务名使用逗号分隔
+// 单个服务名返回[]Service，多个返回map[name][]Service
+//
+// @param ns
+// @param names
+// @param batch 	为true时总是返回map
+// @return interface{}
+//
+func (s *Server) getServices(ns, names string, batch bool) interface{} {
+	list := strings.Split(names, ",")
+	if 1 == len(list) && !batch {
+		return s.GetService(ns, names)
+	}
+
+	services := make(map[string][]*registry.Service)
+	for _, name := range list {
+		if 0 == len(name) {
+			continue
+		}
+
+		services[name] = s.GetService(ns, name)
+	}
+
+	return services
+}
+
+func writeJson(w http.ResponseWriter, obj interface{}) {
+	b, err := json.Marshal(obj)
+	if nil != err {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(b)
+}
@@ -0,0 +1,454 @@
+// Package s2sd is an embeddable s2s registry server, it speaks the same
+// http and tcp protocols as the production s2s.
+package s2sd
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	s2s "github.com/heegspace/heegrpc/registry"
+	"go-micro.dev/v4/logger"
+	"go-micro.dev/v4/registry"
+	"go.uber.org/zap"
+)
+
+type Options struct {
+	// http监听地址，为空则不启动http服务
+	HttpAddr string
+	// tcp监听地址，为空则不启动tcp服务
+	TcpAddr string
+	// 节点默认的过期时间
+	TTL time.Duration
+	// 检查节点过期的间隔
+	Interval time.Duration
+	// 不为nil时http和tcp都使用tls，需要校验客户端证书时设置ClientAuth
+	TLSConfig *tls.Config
+	// 不为nil时校验注册和注销请求的签名
+	Verifier *s2s.Verifier
+}
+
+type Option func(*Options)
+
+// 设置http监听地址
+//
+// @param addr 	例如127.0.0.1:8081，端口为0时随机分配
+//
+func HttpAddr(addr string) Option {
+	return func(o *Options) {
+		o.HttpAddr = addr
+	}
+}
+
+// 设置tcp监听地址
+//
+// @param addr 	例如127.0.0.1:8082，端口为0时随机分配
+//
+func TcpAddr(addr string) Option {
+	return func(o *Options) {
+		o.TcpAddr = addr
+	}
+}
+
+// 设置节点默认的过期时间
+//
+// @param ttl
+//
+func TTL(ttl time.Duration) Option {
+	return func(o *Options) {
+		o.TTL = ttl
+	}
+}
+
+// 设置检查节点过期的间隔
+//
+// @param interval
+//
+func Interval(interval time.Duration) Option {
+	return func(o *Options) {
+		o.Interval = interval
+	}
+}
+
+// 设置http和tcp使用的tls配置
+//
+// @param config
+//
+func TLSConfig(config *tls.Config) Option {
+	return func(o *Options) {
+		o.TLSConfig = config
+	}
+}
+
+// 设置注册和注销请求的签名校验
+//
+// @param v
+//
+func Verifier(v *s2s.Verifier) Option {
+	return func(o *Options) {
+		o.Verifier = v
+	}
+}
+
+type record struct {
+	node   *registry.Node
+	expire time.Time
+}
+
+type entry struct {
+	svc   *registry.Service
+	nodes map[string]*record
+}
+
+type Server struct {
+	opts Options
+
+	rwlock sync.RWMutex
+	// 命名空间/服务名 -> 版本 -> 节点
+	services map[string]map[string]*entry
+
+	connlock sync.RWMutex
+	conns    map[net.Conn]*connState
+
+	httpLn net.Listener
+	tcpLn  net.Listener
+	httpSv *http.Server
+
+	exit chan bool
+	once sync.Once
+}
+
+// 创建s2s服务，调用Start后开始监听
+//
+// @param opts
+// @return *Server
+//
+func New(opts ...Option) *Server {
+	options := Options{
+		HttpAddr: "127.0.0.1:8081",
+		TcpAddr:  "127.0.0.1:8082",
+		TTL:      90 * time.Second,
+		Interval: time.Second,
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	return &Server{
+		opts:     options,
+		services: make(map[string]map[string]*entry),
+		conns:    make(map[net.Conn]*connState),
+		exit:     make(chan bool),
+	}
+}
+
+// 启动http、tcp监听和节点过期检查
+//
+// @return error
+//
+func (s *Server) Start() (err error) {
+	if 0 < len(s.opts.HttpAddr) {
+		s.httpLn, err = s.listen(s.opts.HttpAddr)
+		if nil != err {
+			return
+		}
+
+		s.httpSv = &http.Server{Handler: s.Handler()}
+		go s.httpSv.Serve(s.httpLn)
+	}
+
+	if 0 < len(s.opts.TcpAddr) {
+		s.tcpLn, err = s.listen(s.opts.TcpAddr)
+		if nil != err {
+			s.Stop()
+
+			return
+		}
+
+		go s.acceptTcp()
+	}
+
+	go s.expire()
+
+	logger.Info("s2sd started", zap.Any("http", s.HttpAddr()), zap.Any("tcp", s.TcpAddr()))
+	return
+}
+
+// 停止服务，断开所有连接
+//
+func (s *Server) Stop() {
+	s.once.Do(func() {
+		close(s.exit)
+
+		if nil != s.httpSv {
+			s.httpSv.Close()
+		}
+		if nil != s.tcpLn {
+			s.tcpLn.Close()
+		}
+
+		s.connlock.Lock()
+		for conn := range s.conns {
+			conn.Close()
+		}
+		s.connlock.Unlock()
+	})
+}
+
+// 实际监听的http地址
+//
+// @return string
+//
+func (s *Server) HttpAddr() string {
+	if nil == s.httpLn {
+		return ""
+	}
+
+	return s.httpLn.Addr().String()
+}
+
+// 实际监听的tcp地址
+//
+// @return string
+//
+func (s *Server) TcpAddr() string {
+	if nil == s.tcpLn {
+		return ""
+	}
+
+	return s.tcpLn.Addr().String()
+}
+
+func (s *Server) listen(addr string) (net.Listener, error) {
+	tcpAddr, err := net.ResolveTCPAddr("tcp", addr)
+	if nil != err {
+		return nil, err
+	}
+
+	ln, err := net.ListenTCP("tcp", tcpAddr)
+	if nil != err {
+		return nil, err
+	}
+	if nil != s.opts.TLSConfig {
+		return tls.NewListener(ln, s.opts.TLSConfig), nil
+	}
+
+	return ln, nil
+}
+
+// 服务在命名空间下的key
+//
+// @param ns
+// @param name
+// @return string
+//
+func serviceKey(ns, name string) string {
+	if 0 == len(ns) {
+		return name
+	}
+
+	return ns + "/" + name
+}
+
+// 注册或者更新服务节点
+//
+// @param ns 	命名空间，为空时使用默认的命名空间
+// @param svc 	服务信息
+// @param ttl 	节点过期时间，小于等于0使用默认值
+//
+func (s *Server) Register(ns string, svc *registry.Service, ttl time.Duration) {
+	if nil == svc || 0 == len(svc.Name) {
+		return
+	}
+	if 0 >= ttl {
+		ttl = s.opts.TTL
+	}
+	key := serviceKey(ns, svc.Name)
+
+	s.rwlock.Lock()
+	versions, ok := s.services[key]
+	if !ok {
+		versions = make(map[string]*entry)
+		s.services[key] = versions
+	}
+
+	e, ok := versions[svc.Version]
+	if !ok {
+		e = &entry{nodes: make(map[string]*record)}
+		versions[svc.Version] = e
+	}
+
+	base := *svc
+	base.Nodes = nil
+	e.svc = &base
+
+	expire := time.Now().Add(ttl)
+	for _, n := range svc.Nodes {
+		e.nodes[n.Id] = &record{node: n, expire: expire}
+	}
+	s.rwlock.Unlock()
+
+	s.notify("update", ns, svc.Name)
+}
+
+// 注销服务节点，没有节点的服务会被删除
+//
+// @param ns 	命名空间
+// @param svc 	服务信息
+//
+func (s *Server) Deregister(ns string, svc *registry.Service) {
+	if nil == svc || 0 == len(svc.Name) {
+		return
+	}
+	key := serviceKey(ns, svc.Name)
+
+	s.rwlock.Lock()
+	versions, ok := s.services[key]
+	if !ok {
+		s.rwlock.Unlock()
+
+		return
+	}
+
+	if e, ok := versions[svc.Version]; ok {
+		for _, n := range svc.Nodes {
+			delete(e.nodes, n.Id)
+		}
+
+		if 0 == len(e.nodes) {
+			delete(versions, svc.Version)
+		}
+	}
+
+	if 0 == len(versions) {
+		delete(s.services, key)
+	}
+	s.rwlock.Unlock()
+
+	s.notify("delete", ns, svc.Name)
+}
+
+// 获取服务的所有版本和节点
+//
+// @param ns 	命名空间
+// @param name 	服务名
+// @return {[]Service}
+//
+func (s *Server) GetService(ns, name string) []*registry.Service {
+	s.rwlock.RLock()
+	defer s.rwlock.RUnlock()
+
+	return s.getService(serviceKey(ns, name))
+}
+
+// 获取命名空间下的所有服务
+//
+// @param ns 	命名空间
+// @return {[]Service}
+//
+func (s *Server) ListServices(ns string) []*registry.Service {
+	s.rwlock.RLock()
+	defer s.rwlock.RUnlock()
+
+	keys := make([]string, 0, len(s.services))
+	for key := range s.services {
+		if kns, _ := splitKey(key); kns != ns {
+			continue
+		}
+
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	services := make([]*registry.Service, 0)
+	for _, key := range keys {
+		services = append(services, s.getService(key)...)
+	}
+
+	return services
+}
+
+// 从key中获取命名空间和服务名
+//
+// @param key
+// @return {ns,name}
+//
+func splitKey(key string) (string, string) {
+	if idx := strings.LastIndex(key, "/"); 0 <= idx {
+		return key[:idx], key[idx+1:]
+	}
+
+	return "", key
+}
+
+func (s *Server) getService(key string) []*registry.Service {
+	services := make([]*registry.Service, 0)
+	for _, e := range s.services[key] {
+		svc := *e.svc
+		svc.Nodes = make([]*registry.Node, 0, len(e.nodes))
+		for _, r := range e.nodes {
+			svc.Nodes = append(svc.Nodes, r.node)
+		}
+		sort.Slice(svc.Nodes, func(i, j int) bool {
+			return svc.Nodes[i].Id < svc.Nodes[j].Id
+		})
+
+		services = append(services, &svc)
+	}
+
+	return services
+}
+
+// 定时删除过期的节点
+//
+func (s *Server) expire() {
+	ticker := time.NewTicker(s.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.exit:
+			return
+		}
+
+		now := time.Now()
+		changed := make([]string, 0)
+
+		s.rwlock.Lock()
+		for key, versions := range s.services {
+			removed := false
+			for ver, e := range versions {
+				for id, r := range e.nodes {
+					if now.After(r.expire) {
+						delete(e.nodes, id)
+						removed = true
+					}
+				}
+
+				if 0 == len(e.nodes) {
+					delete(versions, ver)
+				}
+			}
+
+			if 0 == len(versions) {
+				delete(s.services, key)
+			}
+			if removed {
+				changed = append(changed, key)
+			}
+		}
+		s.rwlock.Unlock()
+
+		for _, key := range changed {
+			ns, name := splitKey(key)
+			logger.Debug("s2sd node expired", zap.Any("ns", ns), zap.Any("name", name))
+
+			s.notify("delete", ns, name)
+		}
+	}
+}
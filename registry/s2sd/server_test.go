@@ -0,0 +1,391 @@
+package s2sd
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"math/big"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/heegspace/appcom"
+	"github.com/heegspace/heegrpc/conf"
+	s2s "github.com/heegspace/heegrpc/registry"
+	"go-micro.dev/v4/registry"
+)
+
+func testService(id string) *registry.Service {
+	return &registry.Service{
+		Name:    "test.svr",
+		Version: "1.0.0",
+		Nodes: []*registry.Node{
+			{Id: id, Address: "127.0.0.1:9000"},
+		},
+	}
+}
+
+func Test_Http(t *testing.T) {
+	svr := New(HttpAddr("127.0.0.1:0"), TcpAddr(""))
+	if err := svr.Start(); nil != err {
+		t.Fatal(err)
+	}
+	defer svr.Stop()
+
+	b, _ := json.Marshal(testService("n1"))
+	rsp, err := http.Post("http://"+svr.HttpAddr()+"/registry", "application/json", bytes.NewReader(b))
+	if nil != err {
+		t.Fatal(err)
+	}
+	rsp.Body.Close()
+
+	rsp, err = http.Get("http://" + svr.HttpAddr() + "/registry/test.svr")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer rsp.Body.Close()
+
+	var services []*registry.Service
+	if err := json.NewDecoder(rsp.Body).Decode(&services); nil != err {
+		t.Fatal(err)
+	}
+	if 1 != len(services) || 1 != len(services[0].Nodes) {
+		t.Fatalf("unexpected services %v", services)
+	}
+}
+
+func dialTcp(t *testing.T, addr string) (*net.TCPConn, chan *s2s.StreamRes) {
+	tcpAddr, _ := net.ResolveTCPAddr("tcp", addr)
+	conn, err := net.DialTCP("tcp", nil, tcpAddr)
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	resch := make(chan *s2s.StreamRes, 8)
+	go appcom.ReadFromTcp(conn, func(ctx context.Context, conn *net.TCPConn, size int, data []byte) error {
+		res, _, err := s2s.DecodeRes(data)
+		if nil != err {
+			return err
+		}
+
+		resch <- res
+		return nil
+	}, func(ctx context.Context, conn *net.TCPConn) error {
+		return nil
+	})
+
+	return conn, resch
+}
+
+func Test_Namespace(t *testing.T) {
+	svr := New(HttpAddr(""), TcpAddr(""))
+	svr.Register("dev", testService("n1"), 0)
+
+	if 0 != len(svr.GetService("", "test.svr")) || 0 != len(svr.ListServices("")) {
+		t.Fatal("service visible in default namespace")
+	}
+	if 1 != len(svr.GetService("dev", "test.svr")) || 1 != len(svr.ListServices("dev")) {
+		t.Fatal("service not found in namespace")
+	}
+}
+
+func Test_TcpNotify(t *testing.T) {
+	svr := New(HttpAddr(""), TcpAddr("127.0.0.1:0"))
+	if err := svr.Start(); nil != err {
+		t.Fatal(err)
+	}
+	defer svr.Stop()
+
+	conn, resch := dialTcp(t, svr.TcpAddr())
+	defer conn.Close()
+
+	// 等待连接被服务端接受
+	time.Sleep(100 * time.Millisecond)
+	svr.Register("", testService("n1"), 0)
+
+	select {
+	case res := <-resch:
+		var n s2s.Notify
+		json.Unmarshal([]byte(res.Data), &n)
+		if "notify" != res.Cmd || "update" != res.Code || "test.svr" != n.Name || 1 != len(n.Services) {
+			t.Fatalf("unexpected notify %v", res)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("notify timeout")
+	}
+}
+
+func Test_Hello(t *testing.T) {
+	svr := New(HttpAddr(""), TcpAddr("127.0.0.1:0"))
+	if err := svr.Start(); nil != err {
+		t.Fatal(err)
+	}
+	defer svr.Stop()
+	svr.Register("", testService("n1"), 0)
+
+	conn, resch := dialTcp(t, svr.TcpAddr())
+	defer conn.Close()
+
+	data, _ := s2s.EncodeReq(s2s.ProtoGob, &s2s.StreamReq{Cmd: "hello", Tag: "1", Extra: map[string]string{"version": "1"}})
+	appcom.WriteToConnections(conn, data)
+	data, _ = s2s.EncodeReq(s2s.ProtoV1, &s2s.StreamReq{Cmd: "get", Data: "test.svr", Tag: "2"})
+	appcom.WriteToConnections(conn, data)
+
+	for _, tag := range []string{"1", "2"} {
+		select {
+		case res := <-resch:
+			if tag != res.Tag || s2s.CodeSuccess != res.Code {
+				t.Fatalf("unexpected response %v", res)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("response timeout")
+		}
+	}
+}
+
+func Test_Expire(t *testing.T) {
+	svr := New(HttpAddr(""), TcpAddr(""), Interval(10*time.Millisecond))
+	if err := svr.Start(); nil != err {
+		t.Fatal(err)
+	}
+	defer svr.Stop()
+
+	svr.Register("", testService("n1"), 50*time.Millisecond)
+	if 1 != len(svr.GetService("", "test.svr")) {
+		t.Fatal("register failed")
+	}
+
+	time.Sleep(200 * time.Millisecond)
+	if 0 != len(svr.GetService("", "test.svr")) {
+		t.Fatal("node not expired")
+	}
+}
+
+// 生成ca签发的证书
+func testCert(t *testing.T, ca *x509.Certificate, caKey *ecdsa.PrivateKey, serial int64) (tls.Certificate, *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "s2s"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	parent, signer := ca, caKey
+	if nil == ca {
+		tmpl.IsCA = true
+		tmpl.KeyUsage = x509.KeyUsageCertSign
+		tmpl.BasicConstraintsValid = true
+		parent, signer = tmpl, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, signer)
+	if nil != err {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, cert
+}
+
+func Test_MutualTLS(t *testing.T) {
+	caPair, ca := testCert(t, nil, nil, 1)
+	svrCert, _ := testCert(t, ca, caPair.PrivateKey.(*ecdsa.PrivateKey), 2)
+	cliCert, _ := testCert(t, ca, caPair.PrivateKey.(*ecdsa.PrivateKey), 3)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+
+	svr := New(HttpAddr(""), TcpAddr("127.0.0.1:0"), TLSConfig(&tls.Config{
+		Certificates: []tls.Certificate{svrCert},
+		ClientCAs:    pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}))
+	if err := svr.Start(); nil != err {
+		t.Fatal(err)
+	}
+	defer svr.Stop()
+	svr.Register("", testService("n1"), 0)
+
+	// 没有客户端证书时握手失败
+	conn, err := tls.Dial("tcp", svr.TcpAddr(), &tls.Config{RootCAs: pool})
+	if nil == err {
+		_, err = conn.Read(make([]byte, 1))
+		conn.Close()
+	}
+	if nil == err {
+		t.Fatal("connection without client cert accepted")
+	}
+
+	conn, err = tls.Dial("tcp", svr.TcpAddr(), &tls.Config{RootCAs: pool, Certificates: []tls.Certificate{cliCert}})
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	// tls连接上和tcp一样分帧
+	defer conn.Close()
+
+	resch := make(chan *s2s.StreamRes, 1)
+	go s2s.ReadFrames(conn, func(conn net.Conn, data []byte) error {
+		res, _, err := s2s.DecodeRes(data)
+		if nil == err {
+			resch <- res
+		}
+
+		return err
+	}, func(conn net.Conn) {})
+
+	data, _ := s2s.EncodeReq(s2s.ProtoGob, &s2s.StreamReq{Cmd: "get", Data: "test.svr", Tag: "1"})
+	if err := s2s.WriteFrame(conn, data); nil != err {
+		t.Fatal(err)
+	}
+
+	select {
+	case res := <-resch:
+		if "1" != res.Tag || s2s.CodeSuccess != res.Code {
+			t.Fatalf("unexpected response %v", res)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("response timeout")
+	}
+}
+
+func Test_Sign(t *testing.T) {
+	key := []byte("secret")
+	verifier := s2s.NewSharedVerifier(key, time.Minute)
+	defer verifier.Stop()
+	svr := New(HttpAddr(""), TcpAddr(""), Verifier(verifier))
+
+	b, _ := json.Marshal(testService("n1"))
+	req := &s2s.StreamReq{Cmd: "update", Data: string(b)}
+	if res := svr.handle(req); s2s.CodeSuccess == res.Code {
+		t.Fatal("unsigned request accepted")
+	}
+
+	s2s.SignRequest([]byte("other"), req)
+	if res := svr.handle(req); s2s.CodeSuccess == res.Code {
+		t.Fatal("request signed with wrong key accepted")
+	}
+
+	s2s.SignRequest(key, req)
+	if res := svr.handle(req); s2s.CodeSuccess != res.Code {
+		t.Fatalf("signed request rejected %v", res)
+	}
+	if res := svr.handle(req); s2s.CodeSuccess == res.Code {
+		t.Fatal("replayed request accepted")
+	}
+	if 1 != len(svr.GetService("", "test.svr")) {
+		t.Fatal("register failed")
+	}
+
+	// ttl和first也在签名中
+	req.Extra = map[string]string{"ttl": "30", "first": "first"}
+	s2s.SignRequest(key, req)
+	req.Extra["ttl"] = "3600"
+	if res := svr.handle(req); s2s.CodeSuccess == res.Code {
+		t.Fatal("request with modified ttl accepted")
+	}
+	req.Extra["ttl"] = "30"
+	delete(req.Extra, "first")
+	if res := svr.handle(req); s2s.CodeSuccess == res.Code {
+		t.Fatal("request with modified first accepted")
+	}
+}
+
+// 等待watcher的下一个事件
+func nextResult(t *testing.T, w registry.Watcher) *registry.Result {
+	ch := make(chan *registry.Result, 1)
+	go func() {
+		res, err := w.Next()
+		if nil != err {
+			res = nil
+		}
+
+		ch <- res
+	}()
+
+	select {
+	case res := <-ch:
+		if nil == res {
+			t.Fatal("watcher stopped")
+		}
+
+		return res
+	case <-time.After(3 * time.Second):
+		t.Fatal("watch timeout")
+	}
+
+	return nil
+}
+
+// s2s客户端是进程内的单例，只能连接第一次启动的s2sd
+var registryOnce sync.Once
+
+// 使用s2s客户端连接进程内的s2sd，检查注册、获取和订阅
+func Test_Registry(t *testing.T) {
+	first := false
+	registryOnce.Do(func() {
+		first = true
+	})
+	if !first {
+		t.Skip("s2s client already connected to another s2sd")
+	}
+
+	svr := New(HttpAddr("127.0.0.1:0"), TcpAddr("127.0.0.1:0"))
+	if err := svr.Start(); nil != err {
+		t.Fatal(err)
+	}
+	defer svr.Stop()
+
+	cfg := conf.NewMemory(nil)
+	cfg.Set(svr.TcpAddr(), "s2s", "tcp_addrs")
+	r := s2s.NewRegistry(registry.Addrs(svr.HttpAddr()), s2s.Config(cfg))
+
+	w, err := r.Watch(registry.WatchService("test.svr"))
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+
+	if err := r.Register(testService("n1"), registry.RegisterTTL(time.Minute)); nil != err {
+		t.Fatal(err)
+	}
+
+	res := nextResult(t, w)
+	if "create" != res.Action || "test.svr" != res.Service.Name || 1 != len(res.Service.Nodes) || "n1" != res.Service.Nodes[0].Id {
+		t.Fatalf("unexpected result %v %v", res.Action, res.Service)
+	}
+
+	services, err := r.GetService("test.svr")
+	if nil != err || 1 != len(services) || 1 != len(services[0].Nodes) || "n1" != services[0].Nodes[0].Id {
+		t.Fatalf("unexpected services %v %v", services, err)
+	}
+	if 1 != len(svr.GetService("", "test.svr")) {
+		t.Fatal("service not registered in s2sd")
+	}
+
+	if err := r.Deregister(testService("n1")); nil != err {
+		t.Fatal(err)
+	}
+
+	res = nextResult(t, w)
+	if "delete" != res.Action || "test.svr" != res.Service.Name {
+		t.Fatalf("unexpected result %v %v", res.Action, res.Service)
+	}
+	if _, err := r.GetService("test.svr"); registry.ErrNotFound != err {
+		t.Fatalf("service not removed %v", err)
+	}
+}
@@ -0,0 +1,287 @@
+package s2sd
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	s2s "github.com/heegspace/heegrpc/registry"
+	"go-micro.dev/v4/logger"
+	"go-micro.dev/v4/registry"
+	"go.uber.org/zap"
+)
+
+const (
+	// tls握手的超时时间
+	handshakeTimeout = 10 * time.Second
+	// 每个连接等待发送的通知数，超过后断开连接，客户端重连后重新获取节点
+	notifyQueueSize = 256
+)
+
+// tcp连接的状态
+type connState struct {
+	// 写锁，同时保护version
+	lock    sync.Mutex
+	version int
+
+	// 等待发送的通知，由连接的写协程发送
+	queue chan *s2s.StreamRes
+	done  chan bool
+}
+
+func (s *Server) acceptTcp() {
+	for {
+		conn, err := s.tcpLn.Accept()
+		if nil != err {
+			select {
+			case <-s.exit:
+				return
+			default:
+			}
+
+			logger.Error("s2sd accept err", zap.Error(err))
+			continue
+		}
+
+		go s.serveTcp(conn)
+	}
+}
+
+// 处理一个tcp连接，tls连接先完成握手，之后和tcp一样分帧
+//
+// @param conn
+//
+func (s *Server) serveTcp(conn net.Conn) {
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		tlsConn.SetDeadline(time.Now().Add(handshakeTimeout))
+		err := tlsConn.Handshake()
+		if nil != err {
+			logger.Warn("s2sd tls handshake err", zap.Any("remote", conn.RemoteAddr()), zap.Error(err))
+
+			conn.Close()
+			return
+		}
+		tlsConn.SetDeadline(time.Time{})
+	}
+
+	c := &connState{
+		version: s2s.ProtoGob,
+		queue:   make(chan *s2s.StreamRes, notifyQueueSize),
+		done:    make(chan bool),
+	}
+
+	s.connlock.Lock()
+	s.conns[conn] = c
+	s.connlock.Unlock()
+
+	go s.writeNotify(conn, c)
+	s2s.ReadFrames(conn, s.onRecv, s.onClose)
+}
+
+func (s *Server) onClose(conn net.Conn) {
+	s.connlock.Lock()
+	if c, ok := s.conns[conn]; ok {
+		close(c.done)
+		delete(s.conns, conn)
+	}
+	s.connlock.Unlock()
+
+	conn.Close()
+}
+
+func (s *Server) onRecv(conn net.Conn, data []byte) error {
+	req, version, err := s2s.DecodeReq(data)
+	if nil != err {
+		logger.Error("s2sd decode err", zap.Error(err))
+
+		return err
+	}
+
+	// 协商协议版本，之后的通知使用协商的版本
+	if "hello" == req.Cmd {
+		return s.hello(conn, req)
+	}
+
+	res := s.handle(req)
+	return s.write(conn, res, version)
+}
+
+// 处理客户端的协议协商，hello消息和响应都使用gob
+//
+// @param conn
+// @param req
+// @return error
+//
+func (s *Server) hello(conn net.Conn, req *s2s.StreamReq) error {
+	version, _ := strconv.Atoi(req.Extra["version"])
+	if version > s2s.ProtoLatest {
+		version = s2s.ProtoLatest
+	}
+	if version < s2s.ProtoGob {
+		version = s2s.ProtoGob
+	}
+
+	err := s.write(conn, &s2s.StreamRes{
+		Cmd:  req.Cmd,
+		Code: s2s.CodeSuccess,
+		Data: strconv.Itoa(version),
+		Tag:  req.Tag,
+	}, s2s.ProtoGob)
+	if nil != err {
+		return err
+	}
+
+	s.connlock.RLock()
+	if c, ok := s.conns[conn]; ok {
+		c.lock.Lock()
+		c.version = version
+		c.lock.Unlock()
+	}
+	s.connlock.RUnlock()
+
+	return nil
+}
+
+// 处理tcp请求，返回响应
+//
+// @param req
+// @return *StreamRes
+//
+func (s *Server) handle(req *s2s.StreamReq) *s2s.StreamRes {
+	res := &s2s.StreamRes{
+		Cmd:  req.Cmd,
+		Code: s2s.CodeSuccess,
+		Tag:  req.Tag,
+	}
+
+	switch req.Cmd {
+	case "update", "delete":
+		if nil != s.opts.Verifier {
+			if err := s.opts.Verifier.VerifyRequest(req); nil != err {
+				logger.Warn("s2sd verify sign err", zap.Any("cmd", req.Cmd), zap.Error(err))
+
+				res.Code = s2s.CodeFailed
+				res.Data = err.Error()
+				return res
+			}
+		}
+
+		var svc registry.Service
+		if err := json.Unmarshal([]byte(req.Data), &svc); nil != err {
+			res.Code = s2s.CodeFailed
+			res.Data = err.Error()
+
+			return res
+		}
+
+		if "update" == req.Cmd {
+			ttl, _ := strconv.ParseInt(req.Extra["ttl"], 10, 64)
+			s.Register(req.Extra["ns"], &svc, time.Duration(ttl)*time.Second)
+		} else {
+			s.Deregister(req.Extra["ns"], &svc)
+		}
+
+	case "get", "gets":
+		b, _ := json.Marshal(s.getServices(req.Extra["ns"], req.Data, "gets" == req.Cmd))
+		res.Data = string(b)
+
+	case "list":
+		b, _ := json.Marshal(s.ListServices(req.Extra["ns"]))
+		res.Data = string(b)
+
+	default:
+		res.Code = s2s.CodeFailed
+		res.Data = "unknown cmd " + req.Cmd
+	}
+
+	return res
+}
+
+// 按协议版本写入响应，version小于0时使用连接协商的版本
+//
+// @param conn
+// @param res
+// @param version
+// @return error
+//
+func (s *Server) write(conn net.Conn, res *s2s.StreamRes, version int) error {
+	s.connlock.RLock()
+	c, ok := s.conns[conn]
+	s.connlock.RUnlock()
+	if !ok {
+		return nil
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if 0 > version {
+		version = c.version
+	}
+
+	data, err := s2s.EncodeRes(version, res)
+	if nil != err {
+		return err
+	}
+
+	return s2s.WriteFrame(conn, data)
+}
+
+// 推送服务变化通知给所有tcp连接，通知中带上服务变化后的所有节点
+// 只放入每个连接的发送队列，不等待写入完成，慢的连接不会阻塞注册
+//
+// @param code 	update或delete
+// @param ns 	命名空间
+// @param name 	变化的服务名
+//
+func (s *Server) notify(code, ns, name string) {
+	data, _ := json.Marshal(&s2s.Notify{
+		Ns:       ns,
+		Name:     name,
+		Services: s.GetService(ns, name),
+	})
+	res := &s2s.StreamRes{
+		Cmd:  "notify",
+		Code: code,
+		Data: string(data),
+	}
+
+	s.connlock.RLock()
+	defer s.connlock.RUnlock()
+
+	for conn, c := range s.conns {
+		select {
+		case c.queue <- res:
+		default:
+			// 队列满时断开连接，客户端重连后会重新获取所有节点
+			logger.Warn("s2sd notify queue full, close conn", zap.Any("remote", conn.RemoteAddr()))
+
+			conn.Close()
+		}
+	}
+}
+
+// 按顺序发送连接上的通知，连接断开后退出
+//
+// @param conn
+// @param c
+//
+func (s *Server) writeNotify(conn net.Conn, c *connState) {
+	for {
+		select {
+		case res := <-c.queue:
+			if err := s.write(conn, res, -1); nil != err {
+				logger.Warn("s2sd notify err", zap.Any("remote", conn.RemoteAddr()), zap.Error(err))
+
+				conn.Close()
+				return
+			}
+
+		case <-c.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,3 @@
+#!/bin/bash
+
+protoc --proto_path=. --go_out=.. *.proto
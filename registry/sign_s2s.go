@@ -0,0 +1,318 @@
+package registry
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go-micro.dev/v4/registry"
+)
+
+// 签名在http请求头中的字段
+const (
+	HeaderTimestamp = "X-S2s-Timestamp"
+	HeaderNonce     = "X-S2s-Nonce"
+	HeaderSignature = "X-S2s-Signature"
+)
+
+var (
+	ErrSignMissing = errors.New("s2s sign missing")
+	ErrSignInvalid = errors.New("s2s sign invalid")
+	ErrSignExpired = errors.New("s2s sign expired")
+	ErrSignReplay  = errors.New("s2s sign nonce reused")
+)
+
+type signKeysKey struct{}
+
+// 设置注册和注销请求签名使用的共享密钥
+// 没有设置时读取配置中的s2s.sign_key，为空则不签名
+//
+// @param key
+// @return Option
+//
+func SignKey(key string) registry.Option {
+	return ServiceSignKey("", key)
+}
+
+// 设置单个服务签名使用的密钥，优先于共享密钥
+//
+// @param service 	服务名，为空时设置共享密钥
+// @param key
+// @return Option
+//
+func ServiceSignKey(service, key string) registry.Option {
+	return func(o *registry.Options) {
+		if nil == o.Context {
+			o.Context = context.Background()
+		}
+
+		keys := make(map[string]string)
+		if prev, ok := o.Context.Value(signKeysKey{}).(map[string]string); ok {
+			for k, v := range prev {
+				keys[k] = v
+			}
+		}
+		keys[service] = key
+
+		o.Context = context.WithValue(o.Context, signKeysKey{}, keys)
+	}
+}
+
+// 服务签名使用的密钥，没有配置时返回nil
+//
+// @param service
+// @return []byte
+//
+func (s *proxy) signKey(service string) []byte {
+	if nil != s.opts.Context {
+		if keys, ok := s.opts.Context.Value(signKeysKey{}).(map[string]string); ok {
+			if key, ok := keys[service]; ok {
+				return []byte(key)
+			}
+			if key, ok := keys[""]; ok {
+				return []byte(key)
+			}
+		}
+	}
+
+	key := s2sConfig("sign_key").String("")
+	if 0 == len(key) {
+		return nil
+	}
+
+	return []byte(key)
+}
+
+// 计算签名，签名内容为cmd、命名空间、注册选项、时间戳、nonce和请求数据
+//
+// @param key
+// @param cmd 		update或delete
+// @param ns
+// @param opts 		注册选项，SignOptions的结果
+// @param ts 		unix时间戳，秒
+// @param nonce
+// @param payload 	服务信息的json
+// @return string 	hex编码的hmac-sha256
+//
+func Sign(key []byte, cmd, ns, opts string, ts int64, nonce, payload string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(cmd))
+	mac.Write([]byte{'\n'})
+	mac.Write([]byte(ns))
+	mac.Write([]byte{'\n'})
+	mac.Write([]byte(opts))
+	mac.Write([]byte{'\n'})
+	mac.Write([]byte(strconv.FormatInt(ts, 10)))
+	mac.Write([]byte{'\n'})
+	mac.Write([]byte(nonce))
+	mac.Write([]byte{'\n'})
+	mac.Write([]byte(payload))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// 需要签名的注册选项，改变ttl或者first同样会被拒绝
+//
+// @param ttl 		节点过期时间，单位秒，没有时为空
+// @param first 	是否为首次注册，http请求为空
+// @return string
+//
+func SignOptions(ttl, first string) string {
+	return "ttl=" + ttl + "&first=" + first
+}
+
+func newNonce() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
+
+// 给tcp请求签名，签名信息放在Extra的ts、nonce、sign中
+//
+// @param key
+// @param req
+//
+func SignRequest(key []byte, req *StreamReq) {
+	if nil == req.Extra {
+		req.Extra = make(map[string]string)
+	}
+
+	ts := time.Now().Unix()
+	nonce := newNonce()
+	req.Extra["ts"] = strconv.FormatInt(ts, 10)
+	req.Extra["nonce"] = nonce
+	req.Extra["sign"] = Sign(key, req.Cmd, req.Extra["ns"], SignOptions(req.Extra["ttl"], req.Extra["first"]), ts, nonce, req.Data)
+}
+
+// 给http请求签名，签名信息放在请求头中，url中的ttl也会签名
+//
+// @param key
+// @param r 		已经设置好url的请求
+// @param cmd 		POST为update，DELETE为delete
+// @param ns
+// @param body
+//
+func SignHttp(key []byte, r *http.Request, cmd, ns string, body []byte) {
+	ts := time.Now().Unix()
+	nonce := newNonce()
+	r.Header.Set(HeaderTimestamp, strconv.FormatInt(ts, 10))
+	r.Header.Set(HeaderNonce, nonce)
+	r.Header.Set(HeaderSignature, Sign(key, cmd, ns, SignOptions(r.URL.Query().Get("ttl"), ""), ts, nonce, string(body)))
+}
+
+// s2s服务端校验注册和注销请求的签名
+type Verifier struct {
+	// 按服务名获取密钥，返回nil表示服务不需要签名
+	keys func(service string) []byte
+	// 允许的时间误差，nonce也保存这么久
+	window time.Duration
+
+	lock   sync.Mutex
+	nonces map[string]time.Time
+
+	exit chan bool
+	once sync.Once
+}
+
+// 创建签名校验，后台定时删除过期的nonce，不再使用时调用Stop
+//
+// @param keys 	按服务名获取密钥
+// @param window 	允许的时间误差，小于等于0时为5分钟
+// @return *Verifier
+//
+func NewVerifier(keys func(service string) []byte, window time.Duration) *Verifier {
+	if 0 >= window {
+		window = 5 * time.Minute
+	}
+
+	v := &Verifier{
+		keys:   keys,
+		window: window,
+		nonces: make(map[string]time.Time),
+		exit:   make(chan bool),
+	}
+
+	go v.expire()
+	return v
+}
+
+// 所有服务使用同一个密钥的签名校验
+//
+// @param key
+// @param window
+// @return *Verifier
+//
+func NewSharedVerifier(key []byte, window time.Duration) *Verifier {
+	return NewVerifier(func(string) []byte {
+		return key
+	}, window)
+}
+
+// 停止删除过期的nonce
+//
+func (v *Verifier) Stop() {
+	v.once.Do(func() {
+		close(v.exit)
+	})
+}
+
+// 校验tcp请求的签名，只有update和delete需要签名
+//
+// @param req
+// @return error
+//
+func (v *Verifier) VerifyRequest(req *StreamReq) error {
+	if "update" != req.Cmd && "delete" != req.Cmd {
+		return nil
+	}
+
+	opts := SignOptions(req.Extra["ttl"], req.Extra["first"])
+	return v.verify(req.Cmd, req.Extra["ns"], opts, req.Extra["ts"], req.Extra["nonce"], req.Extra["sign"], req.Data)
+}
+
+// 校验http请求的签名
+//
+// @param r
+// @param cmd
+// @param ns
+// @param body
+// @return error
+//
+func (v *Verifier) VerifyHttp(r *http.Request, cmd, ns string, body []byte) error {
+	opts := SignOptions(r.URL.Query().Get("ttl"), "")
+	return v.verify(cmd, ns, opts, r.Header.Get(HeaderTimestamp), r.Header.Get(HeaderNonce), r.Header.Get(HeaderSignature), string(body))
+}
+
+func (v *Verifier) verify(cmd, ns, opts, tsStr, nonce, sign, payload string) error {
+	var svc registry.Service
+	if err := json.Unmarshal([]byte(payload), &svc); nil != err {
+		return err
+	}
+
+	key := v.keys(svc.Name)
+	if nil == key {
+		return nil
+	}
+	if 0 == len(tsStr) || 0 == len(nonce) || 0 == len(sign) {
+		return ErrSignMissing
+	}
+
+	ts, err := strconv.ParseInt(tsStr, 10, 64)
+	if nil != err {
+		return ErrSignInvalid
+	}
+	now := time.Now()
+	diff := now.Sub(time.Unix(ts, 0))
+	if diff > v.window || diff < -v.window {
+		return ErrSignExpired
+	}
+
+	expect := Sign(key, cmd, ns, opts, ts, nonce, payload)
+	if !hmac.Equal([]byte(expect), []byte(sign)) {
+		return ErrSignInvalid
+	}
+
+	// 签名正确后再记录nonce，避免无效请求占用内存
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	if _, ok := v.nonces[nonce]; ok {
+		return ErrSignReplay
+	}
+	v.nonces[nonce] = now.Add(2 * v.window)
+
+	return nil
+}
+
+// 定时删除过期的nonce，过期前时间戳已经超出允许的误差
+//
+func (v *Verifier) expire() {
+	ticker := time.NewTicker(v.window)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-v.exit:
+			return
+		}
+
+		now := time.Now()
+		v.lock.Lock()
+		for k, expire := range v.nonces {
+			if now.After(expire) {
+				delete(v.nonces, k)
+			}
+		}
+		v.lock.Unlock()
+	}
+}
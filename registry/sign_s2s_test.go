@@ -0,0 +1,60 @@
+package registry
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"go-micro.dev/v4/registry"
+)
+
+func Test_resign(t *testing.T) {
+	key := []byte("secret")
+	v := NewSharedVerifier(key, time.Minute)
+	defer v.Stop()
+
+	b, _ := json.Marshal(&registry.Service{Name: "test.svr"})
+	req := &StreamReq{Cmd: "update", Data: string(b), Tag: "t1", Extra: map[string]string{"ttl": "30"}}
+	SignRequest(key, req)
+	if err := v.VerifyRequest(req); nil != err {
+		t.Fatal(err)
+	}
+	if err := v.VerifyRequest(req); ErrSignReplay != err {
+		t.Fatalf("replay not rejected %v", err)
+	}
+
+	// 重发时使用新的nonce，原请求不变
+	nonce := req.Extra["nonce"]
+	next := resign(req, key)
+	if nonce != req.Extra["nonce"] || nonce == next.Extra["nonce"] || "30" != next.Extra["ttl"] {
+		t.Fatalf("unexpected resign %v %v", req.Extra, next.Extra)
+	}
+	if err := v.VerifyRequest(next); nil != err {
+		t.Fatalf("resigned request rejected %v", err)
+	}
+
+	if resign(req, nil) != req {
+		t.Fatal("unsigned request copied")
+	}
+}
+
+func Test_VerifierExpire(t *testing.T) {
+	v := NewSharedVerifier([]byte("secret"), 50*time.Millisecond)
+	defer v.Stop()
+
+	v.lock.Lock()
+	v.nonces["old"] = time.Now().Add(-time.Second)
+	v.nonces["new"] = time.Now().Add(time.Hour)
+	v.lock.Unlock()
+
+	time.Sleep(200 * time.Millisecond)
+
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	_, old := v.nonces["old"]
+	_, ok := v.nonces["new"]
+	if old || !ok {
+		t.Fatalf("unexpected nonces %v", v.nonces)
+	}
+}
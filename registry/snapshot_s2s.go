@@ -0,0 +1,164 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go-micro.dev/v4/logger"
+	"go-micro.dev/v4/registry"
+	"go.uber.org/zap"
+)
+
+type snapshotKey struct{}
+
+// 服务缓存的快照，保存在本地文件中
+type snapshot struct {
+	SavedAt  int64                          `json:"saved_at"`
+	Services map[string][]*registry.Service `json:"services"`
+}
+
+// 设置服务缓存快照的保存路径，为空则不保存快照
+// 没有设置时读取配置中的s2s.snapshot_file
+//
+// @param path
+// @return Option
+//
+func SnapshotFile(path string) registry.Option {
+	return func(o *registry.Options) {
+		if nil == o.Context {
+			o.Context = context.Background()
+		}
+
+		o.Context = context.WithValue(o.Context, snapshotKey{}, path)
+	}
+}
+
+// 快照文件路径
+//
+// @return string
+//
+func (s *proxy) snapshotFile() string {
+	if nil != s.opts.Context {
+		if path, ok := s.opts.Context.Value(snapshotKey{}).(string); ok {
+			return path
+		}
+	}
+
+	return s2sConfig("snapshot_file").String("")
+}
+
+// 保存服务缓存到快照文件，先写临时文件再重命名，避免写入一半的文件
+//
+// @return error
+//
+func (s *proxy) saveSnapshot() error {
+	path := s.snapshotFile()
+	if 0 == len(path) {
+		return nil
+	}
+
+	s.rwlock.RLock()
+	data, err := json.Marshal(&snapshot{
+		SavedAt:  time.Now().Unix(),
+		Services: s.svrs,
+	})
+	s.rwlock.RUnlock()
+	if nil != err {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if nil != err {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if nil == err {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); nil == err {
+		err = cerr
+	}
+	if nil != err {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// 启动时加载快照，快照中的节点可能已经过期，s2s刷新成功后被替换
+//
+// @return error
+//
+func (s *proxy) loadSnapshot() error {
+	path := s.snapshotFile()
+	if 0 == len(path) || !fileExist(path) {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if nil != err {
+		return err
+	}
+
+	var snap snapshot
+	err = json.Unmarshal(data, &snap)
+	if nil != err {
+		return err
+	}
+
+	// 只加载当前命名空间的服务
+	ns := s.namespace()
+	names := make([]string, 0, len(snap.Services))
+
+	s.rwlock.Lock()
+	for k, v := range snap.Services {
+		kns, name := splitCacheKey(k)
+		if kns != ns {
+			continue
+		}
+
+		names = append(names, name)
+		if _, ok := s.svrs[k]; ok {
+			continue
+		}
+
+		s.svrs[k] = v
+	}
+	s.snapshotAt = time.Unix(snap.SavedAt, 0)
+	s.stale = true
+	s.rwlock.Unlock()
+
+	// 继续刷新快照中的服务
+	for _, name := range names {
+		watchNode.Add(name)
+	}
+
+	logger.Info("load s2s snapshot", zap.Any("path", path), zap.Any("size", len(snap.Services)), zap.Any("age", time.Since(s.snapshotAt)))
+	return nil
+}
+
+// 服务缓存是否还在使用快照中的数据，以及快照保存到现在的时间
+// s2s刷新成功后stale为false
+//
+// @return {age,stale}
+//
+func SnapshotAge() (time.Duration, bool) {
+	if nil == gs {
+		return 0, false
+	}
+
+	gs.rwlock.RLock()
+	defer gs.rwlock.RUnlock()
+
+	if gs.snapshotAt.IsZero() {
+		return 0, gs.stale
+	}
+
+	return time.Since(gs.snapshotAt), gs.stale
+}
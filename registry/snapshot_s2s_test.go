@@ -0,0 +1,63 @@
+package registry
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go-micro.dev/v4/registry"
+)
+
+func Test_Snapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshot")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "s2s.json")
+	svc := &registry.Service{Name: "snap.svr", Version: "1", Nodes: []*registry.Node{{Id: "n1", Address: "127.0.0.1:9000"}}}
+
+	var opts registry.Options
+	SnapshotFile(path)(&opts)
+
+	s := &proxy{
+		opts: opts,
+		svrs: map[string][]*registry.Service{
+			"snap.svr":       {svc},
+			"other/snap.svr": {svc},
+		},
+	}
+	if err := s.saveSnapshot(); nil != err {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name   string
+		cached map[string][]*registry.Service
+		expect string
+	}{
+		// 只加载当前命名空间的服务
+		{"empty cache", map[string][]*registry.Service{}, "127.0.0.1:9000"},
+		// 已经从s2s获取的服务不会被快照覆盖
+		{"keep fresh cache", map[string][]*registry.Service{
+			"snap.svr": {{Name: "snap.svr", Nodes: []*registry.Node{{Id: "n2", Address: "127.0.0.1:9001"}}}},
+		}, "127.0.0.1:9001"},
+	}
+
+	for _, c := range cases {
+		l := &proxy{opts: s.opts, svrs: c.cached}
+		if err := l.loadSnapshot(); nil != err {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+
+		if !l.stale || l.snapshotAt.IsZero() || 1 != len(l.svrs) {
+			t.Errorf("%s: unexpected state %v %v %v", c.name, l.stale, l.snapshotAt, l.svrs)
+			continue
+		}
+		if addr := l.svrs["snap.svr"][0].Nodes[0].Address; addr != c.expect {
+			t.Errorf("%s: unexpected address %s", c.name, addr)
+		}
+	}
+}
@@ -0,0 +1,373 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"go-micro.dev/v4/cmd"
+	"go-micro.dev/v4/logger"
+	"go-micro.dev/v4/registry"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+// 文件中的服务信息，yaml或者json
+//
+//	services:
+//	  - name: user.svr
+//	    version: 1.0.0
+//	    nodes:
+//	      - address: 127.0.0.1:9000
+//	        metadata:
+//	          zone: local
+type staticFile struct {
+	Services []staticService `yaml:"services" json:"services"`
+}
+
+type staticService struct {
+	Name     string            `yaml:"name" json:"name"`
+	Version  string            `yaml:"version" json:"version"`
+	Metadata map[string]string `yaml:"metadata" json:"metadata"`
+	Nodes    []staticNode      `yaml:"nodes" json:"nodes"`
+}
+
+type staticNode struct {
+	// 为空时使用服务名-地址
+	Id       string            `yaml:"id" json:"id"`
+	Address  string            `yaml:"address" json:"address"`
+	Metadata map[string]string `yaml:"metadata" json:"metadata"`
+}
+
+type staticFileKey struct{}
+type staticIntervalKey struct{}
+
+// 设置静态注册使用的文件，没有设置时使用registry.Addrs中的第一个地址
+//
+// @param path
+// @return Option
+//
+func StaticFile(path string) registry.Option {
+	return func(o *registry.Options) {
+		if nil == o.Context {
+			o.Context = context.Background()
+		}
+
+		o.Context = context.WithValue(o.Context, staticFileKey{}, path)
+	}
+}
+
+// 设置检查文件变化的间隔，默认1秒
+//
+// @param interval
+// @return Option
+//
+func StaticInterval(interval time.Duration) registry.Option {
+	return func(o *registry.Options) {
+		if nil == o.Context {
+			o.Context = context.Background()
+		}
+
+		o.Context = context.WithValue(o.Context, staticIntervalKey{}, interval)
+	}
+}
+
+// 从文件中读取服务节点的注册中心，用于本地开发
+// 注册和注销不会修改文件，文件变化后重新加载并通知watcher
+type static struct {
+	opts registry.Options
+
+	path     string
+	interval time.Duration
+
+	rwlock sync.RWMutex
+	svrs   map[string][]*registry.Service
+	mod    time.Time
+
+	watchers map[string]*watcher
+	wlock    sync.RWMutex
+
+	exit chan bool
+	once sync.Once
+}
+
+func init() {
+	cmd.DefaultRegistries["static"] = NewStaticRegistry
+}
+
+// 创建静态注册中心
+//
+// @param opts
+// @return registry.Registry
+//
+func NewStaticRegistry(opts ...registry.Option) registry.Registry {
+	s := &static{
+		svrs:     make(map[string][]*registry.Service),
+		watchers: make(map[string]*watcher),
+		exit:     make(chan bool),
+	}
+	s.Init(opts...)
+
+	err := s.load()
+	if nil != err {
+		logger.Error("load static registry err", zap.Any("path", s.path), zap.Error(err))
+	}
+
+	go s.run()
+	return s
+}
+
+func (s *static) Init(opts ...registry.Option) error {
+	for _, o := range opts {
+		o(&s.opts)
+	}
+
+	s.path = ""
+	s.interval = time.Second
+	if nil != s.opts.Context {
+		if path, ok := s.opts.Context.Value(staticFileKey{}).(string); ok {
+			s.path = path
+		}
+		if interval, ok := s.opts.Context.Value(staticIntervalKey{}).(time.Duration); ok && 0 < interval {
+			s.interval = interval
+		}
+	}
+	if 0 == len(s.path) && 0 < len(s.opts.Addrs) {
+		s.path = s.opts.Addrs[0]
+	}
+	if 0 == len(s.path) {
+		s.path = "registry.yaml"
+	}
+
+	return nil
+}
+
+func (s *static) Options() registry.Options {
+	return s.opts
+}
+
+// 本地服务的节点需要写在文件中，注册不做任何处理
+func (s *static) Register(service *registry.Service, opts ...registry.RegisterOption) error {
+	logger.Debug("static registry ignore register", zap.Any("name", service.Name))
+
+	return nil
+}
+
+func (s *static) Deregister(service *registry.Service, opts ...registry.DeregisterOption) error {
+	return nil
+}
+
+func (s *static) GetService(service string, opts ...registry.GetOption) ([]*registry.Service, error) {
+	if 0 == len(service) {
+		return nil, errors.New("Service name is nil")
+	}
+
+	var gopts registry.GetOptions
+	for _, o := range opts {
+		o(&gopts)
+	}
+
+	s.rwlock.RLock()
+	services := s.svrs[service]
+	s.rwlock.RUnlock()
+
+	services = applyFilters(copyServices(services), append(contextFilters(s.opts.Context), contextFilters(gopts.Context)...))
+	if 0 == len(services) {
+		return nil, registry.ErrNotFound
+	}
+
+	return services, nil
+}
+
+func (s *static) ListServices(opts ...registry.ListOption) ([]*registry.Service, error) {
+	s.rwlock.RLock()
+	defer s.rwlock.RUnlock()
+
+	names := make([]string, 0, len(s.svrs))
+	for name := range s.svrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	services := make([]*registry.Service, 0, len(names))
+	for _, name := range names {
+		services = append(services, copyServices(s.svrs[name])...)
+	}
+
+	return services, nil
+}
+
+func (s *static) Watch(opts ...registry.WatchOption) (registry.Watcher, error) {
+	var wo registry.WatchOptions
+	for _, o := range opts {
+		o(&wo)
+	}
+
+	w := newWatcher(getRandomTag(), wo, func(id string) {
+		s.wlock.Lock()
+		delete(s.watchers, id)
+		s.wlock.Unlock()
+	})
+
+	s.wlock.Lock()
+	s.watchers[w.id] = w
+	s.wlock.Unlock()
+
+	return w, nil
+}
+
+func (s *static) String() string {
+	return "static"
+}
+
+// 停止检查文件变化
+//
+func (s *static) Stop() {
+	s.once.Do(func() {
+		close(s.exit)
+	})
+}
+
+// 解析服务文件，同一个服务名的节点按版本分组
+//
+// @param data
+// @return {map[name][]Service,error}
+//
+func parseStatic(data []byte) (map[string][]*registry.Service, error) {
+	// json是yaml的子集，两种格式都使用yaml解析
+	var file staticFile
+	err := yaml.Unmarshal(data, &file)
+	if nil != err {
+		return nil, err
+	}
+
+	svrs := make(map[string][]*registry.Service)
+	for _, v := range file.Services {
+		if 0 == len(v.Name) {
+			continue
+		}
+
+		var svc *registry.Service
+		for _, exist := range svrs[v.Name] {
+			if exist.Version == v.Version {
+				svc = exist
+			}
+		}
+		if nil == svc {
+			svc = &registry.Service{
+				Name:     v.Name,
+				Version:  v.Version,
+				Metadata: v.Metadata,
+				Nodes:    make([]*registry.Node, 0, len(v.Nodes)),
+			}
+			svrs[v.Name] = append(svrs[v.Name], svc)
+		}
+
+		for _, n := range v.Nodes {
+			id := n.Id
+			if 0 == len(id) {
+				id = v.Name + "-" + n.Address
+			}
+
+			svc.Nodes = append(svc.Nodes, &registry.Node{
+				Id:       id,
+				Address:  n.Address,
+				Metadata: n.Metadata,
+			})
+		}
+	}
+
+	return svrs, nil
+}
+
+// 文件有变化时重新加载，并通知watcher节点的变化
+//
+// @return error
+//
+func (s *static) load() error {
+	info, err := os.Stat(s.path)
+	if nil != err {
+		return err
+	}
+
+	s.rwlock.RLock()
+	mod := s.mod
+	s.rwlock.RUnlock()
+	if info.ModTime().Equal(mod) {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(s.path)
+	if nil != err {
+		return err
+	}
+
+	svrs, err := parseStatic(data)
+	if nil != err {
+		return err
+	}
+
+	s.rwlock.Lock()
+	old := s.svrs
+	s.svrs = svrs
+	s.mod = info.ModTime()
+	s.rwlock.Unlock()
+
+	results := make([]*registry.Result, 0)
+	for name, v := range svrs {
+		results = append(results, diffServices(old[name], v)...)
+	}
+	for name, v := range old {
+		if _, ok := svrs[name]; !ok {
+			results = append(results, diffServices(v, nil)...)
+		}
+	}
+
+	logger.Info("load static registry", zap.Any("path", s.path), zap.Any("services", len(svrs)), zap.Any("events", len(results)))
+	s.broadcast(results)
+	return nil
+}
+
+func (s *static) broadcast(results []*registry.Result) {
+	if 0 == len(results) {
+		return
+	}
+
+	s.wlock.RLock()
+	watchers := make([]*watcher, 0, len(s.watchers))
+	for _, w := range s.watchers {
+		watchers = append(watchers, w)
+	}
+	s.wlock.RUnlock()
+
+	// 每个watcher使用单独的副本
+	for _, res := range results {
+		for _, w := range watchers {
+			w.notify(&registry.Result{Action: res.Action, Service: copyService(res.Service)})
+		}
+	}
+}
+
+// 定时检查文件的修改时间
+//
+func (s *static) run() {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-s.exit:
+			return
+		}
+
+		err := s.load()
+		if nil != err {
+			logger.Warn("reload static registry err", zap.Any("path", s.path), zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package registry
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go-micro.dev/v4/registry"
+)
+
+func Test_StaticRegistry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "static")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "registry.yaml")
+	ioutil.WriteFile(path, []byte(`
+services:
+  - name: test.svr
+    version: 1.0.0
+    nodes:
+      - address: 127.0.0.1:9000
+`), 0644)
+
+	r := NewStaticRegistry(StaticFile(path), StaticInterval(10*time.Millisecond))
+	defer r.(*static).Stop()
+
+	services, err := r.GetService("test.svr")
+	if nil != err || 1 != len(services) || "test.svr-127.0.0.1:9000" != services[0].Nodes[0].Id {
+		t.Fatalf("unexpected services %v %v", services, err)
+	}
+
+	w, _ := r.Watch(registry.WatchService("test.svr"))
+	defer w.Stop()
+
+	// json格式，修改时间变化后重新加载
+	ioutil.WriteFile(path, []byte(`{"services": [{"name": "test.svr", "version": "1.0.0", "nodes": [{"id": "n2", "address": "127.0.0.1:9001"}]}]}`), 0644)
+	os.Chtimes(path, time.Now(), time.Now().Add(time.Second))
+
+	res, err := w.Next()
+	if nil != err || "update" != res.Action {
+		t.Fatalf("unexpected result %v %v", res, err)
+	}
+
+	// 修改返回的服务和事件不影响注册中心中的节点
+	res.Service.Nodes[0].Id = "changed"
+	res.Service.Nodes = append(res.Service.Nodes, &registry.Node{Id: "watch"})
+	services, _ = r.GetService("test.svr")
+	if 1 != len(services[0].Nodes) || "n2" != services[0].Nodes[0].Id {
+		t.Fatalf("unexpected services %v", services[0].Nodes)
+	}
+
+	services[0].Nodes[0].Metadata = map[string]string{"k": "v"}
+	services[0].Nodes = append(services[0].Nodes, &registry.Node{Id: "get"})
+	all, _ := r.ListServices()
+	all[0].Nodes = append(all[0].Nodes, &registry.Node{Id: "list"})
+
+	services, _ = r.GetService("test.svr")
+	if 1 != len(services[0].Nodes) || "n2" != services[0].Nodes[0].Id || nil != services[0].Nodes[0].Metadata {
+		t.Fatalf("registry modified by caller %v", services[0].Nodes)
+	}
+}
@@ -0,0 +1,250 @@
+package registry
+
+import (
+	"encoding/json"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/cpu"
+	"github.com/shirou/gopsutil/v3/disk"
+	"github.com/shirou/gopsutil/v3/host"
+	"github.com/shirou/gopsutil/v3/load"
+	"github.com/shirou/gopsutil/v3/mem"
+	"github.com/shirou/gopsutil/v3/net"
+	"github.com/shirou/gopsutil/v3/process"
+	"go-micro.dev/v4/logger"
+	"go-micro.dev/v4/registry"
+	"go-micro.dev/v4/util/addr"
+	"go.uber.org/zap"
+)
+
+type SysInfo struct {
+	HostName   string   `json:"hostname,omitempty"`
+	OS         string   `json:"os,omitempty"`
+	CpuNum     int      `json:"cpu_num,omitempty"`
+	CpuPercent float64  `json:"cpu_percent,omitempty"`
+	MemTotal   uint64   `json:"mem_total,omitempty"`
+	MemUsed    uint64   `json:"mem_used,omitempty"`
+	DiskTotal  uint64   `json:"disk_total,omitempty"`
+	DiskUsed   uint64   `json:"disk_used,omitempty"`
+	Ips        []string `json:"ips,omitempty"`
+
+	// 机器负载
+	Load1  float64 `json:"load1,omitempty"`
+	Load5  float64 `json:"load5,omitempty"`
+	Load15 float64 `json:"load15,omitempty"`
+	// 网卡累计收发的字节数，以及采样间隔内的速率
+	NetSent     uint64  `json:"net_sent,omitempty"`
+	NetRecv     uint64  `json:"net_recv,omitempty"`
+	NetSentRate float64 `json:"net_sent_rate,omitempty"`
+	NetRecvRate float64 `json:"net_recv_rate,omitempty"`
+
+	// 进程信息
+	ProcCpu    float64 `json:"proc_cpu,omitempty"`
+	ProcRss    uint64  `json:"proc_rss,omitempty"`
+	Goroutines int     `json:"goroutines,omitempty"`
+	OpenFds    int32   `json:"open_fds,omitempty"`
+
+	// 采样时间，unix时间戳
+	UpdatedAt int64 `json:"updated_at,omitempty"`
+}
+
+// 后台定时采集系统信息，注册时直接使用最近一次的结果
+type sysCollector struct {
+	lock sync.RWMutex
+	info SysInfo
+	data string
+
+	proc *process.Process
+}
+
+var collector *sysCollector
+var collectorOnce sync.Once
+
+// 获取系统信息采集器，第一次调用时启动后台采集
+// 采集间隔读取配置中的s2s.sysinfo_interval，默认10秒
+//
+// @return *sysCollector
+//
+func sysinfoCollector() *sysCollector {
+	collectorOnce.Do(func() {
+		collector = &sysCollector{}
+
+		proc, err := process.NewProcess(int32(os.Getpid()))
+		if nil == err {
+			collector.proc = proc
+		}
+
+		// 第一次采集不计算cpu，避免阻塞注册
+		collector.collect(0)
+
+		interval := s2sConfig("sysinfo_interval").Int(10)
+		if 0 >= interval {
+			interval = 10
+		}
+		go collector.run(time.Duration(interval) * time.Second)
+	})
+
+	return collector
+}
+
+func (c *sysCollector) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		}
+
+		c.collect(interval)
+	}
+}
+
+// 采集一次系统信息，cpu使用率为两次采集之间的平均值
+//
+// @param elapsed 	距离上次采集的时间，用于计算网络速率
+//
+func (c *sysCollector) collect(elapsed time.Duration) {
+	var sysinfo SysInfo
+
+	hostinfo, err := host.Info()
+	if nil == err {
+		sysinfo.HostName = hostinfo.Hostname
+		sysinfo.OS = hostinfo.OS
+	}
+
+	count, err := cpu.Counts(true)
+	if nil == err {
+		sysinfo.CpuNum = count
+	}
+
+	percent, err := cpu.Percent(0, false)
+	if nil == err && 0 < len(percent) {
+		sysinfo.CpuPercent = percent[0]
+	}
+
+	memInfo, err := mem.VirtualMemory()
+	if nil == err && nil != memInfo {
+		sysinfo.MemTotal = memInfo.Total
+		sysinfo.MemUsed = memInfo.Used
+	}
+
+	parts, err := disk.Partitions(true)
+	if nil == err && 0 < len(parts) {
+		for _, v := range parts {
+			diskInfo, err := disk.Usage(v.Mountpoint)
+			if nil != err {
+				continue
+			}
+
+			sysinfo.DiskTotal = sysinfo.DiskTotal + diskInfo.Total
+			sysinfo.DiskUsed = sysinfo.DiskUsed + diskInfo.Used
+		}
+	}
+
+	avg, err := load.Avg()
+	if nil == err {
+		sysinfo.Load1 = avg.Load1
+		sysinfo.Load5 = avg.Load5
+		sysinfo.Load15 = avg.Load15
+	}
+
+	counters, err := net.IOCounters(false)
+	if nil == err && 0 < len(counters) {
+		sysinfo.NetSent = counters[0].BytesSent
+		sysinfo.NetRecv = counters[0].BytesRecv
+	}
+
+	if nil != c.proc {
+		procCpu, err := c.proc.Percent(0)
+		if nil == err {
+			sysinfo.ProcCpu = procCpu
+		}
+
+		memInfo, err := c.proc.MemoryInfo()
+		if nil == err && nil != memInfo {
+			sysinfo.ProcRss = memInfo.RSS
+		}
+
+		fds, err := c.proc.NumFDs()
+		if nil == err {
+			sysinfo.OpenFds = fds
+		}
+	}
+
+	sysinfo.Goroutines = runtime.NumGoroutine()
+	sysinfo.Ips = addr.IPs()
+	sysinfo.UpdatedAt = time.Now().Unix()
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if 0 < elapsed && sysinfo.NetSent >= c.info.NetSent && sysinfo.NetRecv >= c.info.NetRecv {
+		sysinfo.NetSentRate = float64(sysinfo.NetSent-c.info.NetSent) / elapsed.Seconds()
+		sysinfo.NetRecvRate = float64(sysinfo.NetRecv-c.info.NetRecv) / elapsed.Seconds()
+	}
+
+	info, err := json.Marshal(sysinfo)
+	if nil != err {
+		logger.Warn("marshal sysinfo err", zap.Error(err))
+
+		return
+	}
+
+	c.info = sysinfo
+	c.data = string(info)
+}
+
+func getsysinfo() string {
+	c := sysinfoCollector()
+
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.data
+}
+
+// 复制服务信息并带上最新的系统信息，不修改调用方的服务信息
+//
+// @param service
+// @return *registry.Service
+//
+func withSysinfo(service *registry.Service) *registry.Service {
+	svc := *service
+	svc.Metadata = make(map[string]string, len(service.Metadata)+1)
+	for k, v := range service.Metadata {
+		svc.Metadata[k] = v
+	}
+	svc.Metadata["sysinfo"] = getsysinfo()
+
+	return &svc
+}
+
+// 定时把最新的系统信息推送到s2s，用于按负载路由
+// 推送间隔读取配置中的s2s.sysinfo_push_interval，默认30秒，小于等于0不推送
+//
+func (s *proxy) pushSysinfo() {
+	interval := s2sConfig("sysinfo_push_interval").Int(30)
+	if 0 >= interval {
+		return
+	}
+
+	ticker := time.NewTicker(time.Duration(interval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		}
+
+		for _, l := range s.dueLocals(true) {
+			err := s.registerLocal(l, false)
+			if nil != err {
+				logger.Warn("push sysinfo err", zap.Any("name", l.name), zap.Error(err))
+			}
+		}
+	}
+}
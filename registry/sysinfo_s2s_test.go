@@ -0,0 +1,51 @@
+package registry
+
+import (
+	"testing"
+
+	"go-micro.dev/v4/registry"
+)
+
+func Test_withSysinfo(t *testing.T) {
+	// 其他用例没有启动采集时使用固定的结果，不启动后台采集
+	collectorOnce.Do(func() {
+		collector = &sysCollector{data: `{"hostname":"test"}`}
+	})
+
+	cases := []struct {
+		name     string
+		metadata map[string]string
+	}{
+		{"nil metadata", nil},
+		{"empty metadata", map[string]string{}},
+		{"with metadata", map[string]string{"env": "test"}},
+		{"stale sysinfo", map[string]string{"env": "test", "sysinfo": "old"}},
+	}
+
+	for _, c := range cases {
+		service := &registry.Service{Name: "test.svr", Version: "1", Metadata: c.metadata}
+		before := len(c.metadata)
+		old := c.metadata["sysinfo"]
+
+		svc := withSysinfo(service)
+		if svc == service {
+			t.Fatalf("%s: service not copied", c.name)
+		}
+		if getsysinfo() != svc.Metadata["sysinfo"] || "" == svc.Metadata["sysinfo"] {
+			t.Errorf("%s: unexpected sysinfo %q", c.name, svc.Metadata["sysinfo"])
+		}
+		if "test.svr" != svc.Name || "1" != svc.Version {
+			t.Errorf("%s: unexpected service %+v", c.name, svc)
+		}
+		for k, v := range c.metadata {
+			if "sysinfo" != k && svc.Metadata[k] != v {
+				t.Errorf("%s: metadata %s lost", c.name, k)
+			}
+		}
+
+		// 调用方的服务信息不变
+		if before != len(service.Metadata) || old != service.Metadata["sysinfo"] {
+			t.Errorf("%s: caller metadata modified %v", c.name, service.Metadata)
+		}
+	}
+}
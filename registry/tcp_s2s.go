@@ -1,16 +1,15 @@
 package registry
 
 import (
-	"bytes"
 	"context"
-	"encoding/gob"
+	"errors"
 	"fmt"
 	"net"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/heegspace/appcom"
-	"github.com/heegspace/heegapo"
 	"go-micro.dev/v4/logger"
 	"go.uber.org/zap"
 )
@@ -29,80 +28,351 @@ type StreamRes struct {
 	Tag  string `json:"tag"`
 }
 
+// StreamRes.Code的取值，notify消息中Code为update或delete
+const (
+	CodeSuccess = "0"
+	CodeFailed  = "1"
+)
+
 type tcpS2s struct {
-	conn   *net.TCPConn
+	conn   net.Conn
 	rwlock sync.RWMutex
 
-	addr string
+	endpoints []*endpoint
+	current   int
+	eplock    sync.Mutex
+	pending   *pending
+
+	state   int32
+	backoff Backoff
+
+	// 当前连接协商的协议版本，legacy为true时只使用gob
+	version int32
+	legacy  bool
+	// 同一时间只有一个协程在重连
+	connlock sync.Mutex
+
+	// 不为nil时使用tls连接
+	tls     *s2sTLS
+	tlslock sync.RWMutex
 }
 
+var ErrNotConnected = errors.New("s2s not connected")
+
 var once sync.Once
 var g_s2sCli *tcpS2s
 
 func TcpS2s() *tcpS2s {
 	once.Do(func() {
 		addr := ""
-		ip := heegapo.DefaultApollo.Config("heegspace.common.yaml", "s2s", "tcp_ip").String("")
-		port := heegapo.DefaultApollo.Config("heegspace.common.yaml", "s2s", "tcp_port").Int64(-1)
-		logger.Debug("TcpS2s", zap.Any("ip", ip), zap.Any("port", port))
+		ip := s2sConfig("tcp_ip").String("")
+		port := s2sConfig("tcp_port").Int64(-1)
+		addrs := s2sConfig("tcp_addrs").String("")
+		logger.Debug("TcpS2s", zap.Any("ip", ip), zap.Any("port", port), zap.Any("addrs", addrs))
 
 		if len(ip) != 0 && 0 < port {
 			addr = fmt.Sprintf("%s:%d", ip, port)
 		}
+
+		backoff := DefaultBackoff
+		backoff.MaxAttempts = s2sConfig("reconnect_attempts").Int(backoff.MaxAttempts)
+		backoff.Deadline = time.Duration(s2sConfig("reconnect_deadline").Int(int(backoff.Deadline/time.Second))) * time.Second
+
 		if nil == g_s2sCli {
 			g_s2sCli = &tcpS2s{
-				rwlock: sync.RWMutex{},
-				addr:   addr,
+				rwlock:    sync.RWMutex{},
+				endpoints: parseEndpoints(addrs, addr),
+				current:   0,
+				eplock:    sync.Mutex{},
+				pending:   newPending(),
+				state:     int32(StateIdle),
+				backoff:   backoff,
+				version:   ProtoGob,
+				legacy:    "gob" == s2sConfig("protocol").String(""),
 			}
 		}
+
+		// 多个地址时检查健康状态用于故障切换
+		if 1 < len(g_s2sCli.endpoints) {
+			interval := s2sConfig("health_interval").Int(5)
+			go g_s2sCli.healthCheck(time.Duration(interval) * time.Second)
+		}
 	})
 
 	return g_s2sCli
 }
 
 func (this *tcpS2s) enable() bool {
-	if len(this.addr) != 0 {
+	if len(this.endpoints) != 0 {
 		return true
 	}
 
 	return false
 }
 
-func (this *tcpS2s) GetConn() *net.TCPConn {
+func (this *tcpS2s) GetConn() net.Conn {
 	this.rwlock.RLock()
 	defer this.rwlock.RUnlock()
 
 	return this.conn
 }
 
-func (this *tcpS2s) reset() {
+func (this *tcpS2s) reset(conn net.Conn) {
 	this.rwlock.Lock()
-	this.conn = nil
+	if this.conn == conn {
+		this.conn = nil
+	}
 	this.rwlock.Unlock()
 }
 
-func (this *tcpS2s) Connect() {
-	if len(this.addr) == 0 {
-		return
+// 当前的连接状态
+//
+// @return ConnState
+//
+func (this *tcpS2s) State() ConnState {
+	return ConnState(atomic.LoadInt32(&this.state))
+}
+
+func (this *tcpS2s) setState(state ConnState) {
+	old := ConnState(atomic.SwapInt32(&this.state, int32(state)))
+	if old != state {
+		logger.Info("s2s connection state changed", zap.Any("from", old.String()), zap.Any("to", state.String()))
+	}
+}
+
+// 按重连策略连接s2s，重试次数或时间用完后返回错误
+//
+// @return error
+//
+func (this *tcpS2s) Connect() error {
+	return this.reconnect(context.Background(), nil)
+}
+
+// 重新连接s2s，failed为出错的连接
+// 如果其他协程已经替换了出错的连接则直接返回
+// 只在建立连接时持有connlock，重试等待期间不阻塞其他协程
+//
+// @param ctx
+// @param failed
+// @return error
+//
+func (this *tcpS2s) reconnect(ctx context.Context, failed net.Conn) error {
+	if !this.enable() {
+		return errors.New("s2s tcp address is empty")
+	}
+
+	if 0 < this.backoff.Deadline {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, this.backoff.Deadline)
+		defer cancel()
+	}
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		// 等待重试期间调用方可能已经超时
+		if nil != ctx.Err() {
+			this.setState(StateDegraded)
+
+			return fmt.Errorf("connect to s2s: %v, last err: %v", ctx.Err(), err)
+		}
+
+		var ep *endpoint
+		var done bool
+		ep, done, err = this.connect(ctx, attempt, failed)
+		if done {
+			return nil
+		}
+		if nil == ep {
+			return err
+		}
+
+		// 最后一次失败后直接返回，不再等待
+		if 0 < this.backoff.MaxAttempts && attempt+1 >= this.backoff.MaxAttempts {
+			break
+		}
+
+		delay := this.backoff.Delay(attempt)
+		logger.Error("Connect to s2s fail!", zap.Any("addr", ep.addr), zap.Any("attempt", attempt), zap.Any("retry", delay), zap.Error(err))
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+		}
+	}
+
+	logger.Error("Connect to s2s fail!", zap.Any("attempts", this.backoff.MaxAttempts), zap.Error(err))
+
+	this.setState(StateDegraded)
+	return fmt.Errorf("connect to s2s failed after %d attempts: %v", this.backoff.MaxAttempts, err)
+}
+
+// 尝试一次连接，持有connlock，其他协程已经连接上时直接返回
+//
+// @param ctx
+// @param attempt
+// @param failed 	出错的连接，还是当前连接时关闭
+// @return {endpoint,done,error} 	done为true表示已经有可用的连接
+//
+func (this *tcpS2s) connect(ctx context.Context, attempt int, failed net.Conn) (*endpoint, bool, error) {
+	this.connlock.Lock()
+	defer this.connlock.Unlock()
+
+	conn := this.GetConn()
+	if nil != conn && conn != failed {
+		return nil, true, nil
+	}
+	if nil != conn {
+		conn.Close()
+		this.reset(conn)
+	}
+
+	this.setState(StateConnecting)
+
+	ep := this.pickEndpoint(attempt)
+	conn, err := this.dial(ctx, ep.addr)
+	if nil != err {
+		ep.setHealthy(false)
+
+		return ep, false, err
+	}
+	ep.setHealthy(true)
+
+	this.rwlock.Lock()
+	this.conn = conn
+	this.rwlock.Unlock()
+
+	// 新连接需要重新协商协议版本
+	atomic.StoreInt32(&this.version, ProtoGob)
+
+	this.setState(StateConnected)
+	logger.Info("Connect to s2s success!", zap.Any("addr", ep.addr), zap.Any("attempt", attempt))
+	return ep, true, nil
+}
+
+// 设置连接使用的tls配置，下次建立连接时生效
+//
+// @param t 	为nil时不使用tls
+//
+func (this *tcpS2s) setTLS(t *s2sTLS) {
+	this.tlslock.Lock()
+	this.tls = t
+	this.tlslock.Unlock()
+}
+
+// 连接s2s地址，配置了tls时建立tls连接
+//
+// @param ctx
+// @param addr
+// @return {net.Conn,error}
+//
+func (this *tcpS2s) dial(ctx context.Context, addr string) (net.Conn, error) {
+	this.tlslock.RLock()
+	t := this.tls
+	this.tlslock.RUnlock()
+
+	if nil != t {
+		// tls连接上和tcp一样分帧
+		return t.dial(ctx, "tcp4", addr)
+	}
+
+	dialer := &net.Dialer{Timeout: dialTimeout}
+	return dialer.DialContext(ctx, "tcp4", addr)
+}
+
+// 发送请求到s2s，不等待响应
+// 连接断开时重连，重连最多等待到ctx结束
+// 等待响应的请求写入失败后不在这里重试，由onStart在新连接上重发
+//
+// @param ctx
+// @param req
+// @return error
+//
+func (this *tcpS2s) send(ctx context.Context, req *StreamReq) error {
+	data, err := EncodeReq(this.Version(), req)
+	if nil != err {
+		return err
 	}
 
-	for {
-		tcpAddr, err := net.ResolveTCPAddr("tcp4", this.addr)
-		conn, err := net.DialTCP("tcp", nil, tcpAddr)
-		if err != nil {
-			logger.Error("Connect to s2s fail, 2s reconnected!", zap.Error(err))
+	conn := this.GetConn()
+	if nil == conn {
+		err = this.reconnect(ctx, nil)
+		if nil != err {
+			return err
+		}
 
-			time.Sleep(2 * time.Second)
-			continue
+		// 重连后连接可能又被断开
+		conn = this.GetConn()
+		if nil == conn {
+			return ErrNotConnected
 		}
+	}
+
+	// 已经由resend在这个连接上发送过
+	if !this.pending.claim(req.Tag, conn) {
+		return nil
+	}
+
+	err = WriteFrame(conn, data)
+	if nil == err {
+		return nil
+	}
+
+	logger.Error("send to s2s err", zap.Any("cmd", req.Cmd), zap.Error(err))
+	rerr := this.reconnect(ctx, conn)
+	if nil != rerr {
+		return rerr
+	}
+	if this.pending.has(req.Tag) {
+		return nil
+	}
+
+	return err
+}
+
+// 当前连接使用的协议版本
+//
+// @return int
+//
+func (this *tcpS2s) Version() int {
+	return int(atomic.LoadInt32(&this.version))
+}
+
+// 与s2s协商协议版本，hello消息使用gob编码
+// 旧版本s2s不支持hello时继续使用gob
+//
+// @param conn 	协商的连接，连接已经切换时放弃结果
+//
+func (this *tcpS2s) handshake(conn net.Conn) {
+	if this.legacy {
+		return
+	}
+
+	req := &StreamReq{
+		Cmd:   "hello",
+		Extra: map[string]string{"version": strconv.Itoa(ProtoLatest)},
+	}
+	res, err := this.Call(context.Background(), req, 0)
+	if nil != err {
+		logger.Info("s2s handshake failed, use gob", zap.Error(err))
+
+		return
+	}
 
-		this.rwlock.Lock()
-		this.conn = conn
-		this.rwlock.Unlock()
-		break
+	version, err := strconv.Atoi(res.Data)
+	if nil != err || ProtoGob >= version || ProtoLatest < version {
+		logger.Info("s2s handshake unsupported version, use gob", zap.Any("version", res.Data))
+
+		return
+	}
+
+	if conn != this.GetConn() {
+		return
 	}
 
-	logger.Info("Connect to s2s success!")
+	atomic.StoreInt32(&this.version, int32(version))
+	logger.Info("s2s handshake success", zap.Any("version", version))
 }
 
 func (s *proxy) onStart() {
@@ -112,68 +382,65 @@ func (s *proxy) onStart() {
 
 	go func() {
 		i := 0
+		var last net.Conn
 		for {
-			appcom.ReadFromTcp(TcpS2s().GetConn(), func(ctx context.Context, conn *net.TCPConn, size int, data []byte) (err error) {
-				var res StreamRes
-				buf := bytes.NewBufferString(string(data))
-				dec := gob.NewDecoder(buf)
-				err = dec.Decode(&res)
+			conn := TcpS2s().GetConn()
+			if nil == conn {
+				err := TcpS2s().Connect()
+				if nil != err {
+					logger.Error("s2s unreachable, retry later", zap.Any("state", TcpS2s().State().String()), zap.Error(err))
+
+					time.Sleep(TcpS2s().backoff.Max)
+					continue
+				}
+
+				conn = TcpS2s().GetConn()
+			}
+
+			// 重连后s2s可能已经删除了本地服务的节点，断开期间的通知也已经丢失
+			// 切换地址后在新的s2s上重新注册，重发等待响应的请求并刷新订阅的服务
+			// 重新注册需要等待响应，在开始读取之后进行
+			if nil != last && conn != last {
+				TcpS2s().resend(conn)
+				go s.reregister()
+				s.requestRefresh()
+			}
+			last = conn
+
+			go TcpS2s().handshake(conn)
+			ReadFrames(conn, func(conn net.Conn, data []byte) (err error) {
+				res, _, err := DecodeRes(data)
 				if nil != err {
 					logger.Error("ReadFromTcp err", zap.Error(err))
 
 					return
 				}
 
-				logger.Debug("ReadFromTcp start", zap.Any("size", size), zap.Any("cmd", res.Cmd), zap.Any("code", res.Code), zap.Any("tag", res.Tag))
+				logger.Debug("ReadFromTcp start", zap.Any("size", len(data)), zap.Any("cmd", res.Cmd), zap.Any("code", res.Code), zap.Any("tag", res.Tag))
 				if "notify" != res.Cmd {
-					switch res.Cmd {
-					case "update":
-
-					case "delete":
-
-					case "get":
-						s.chlock.RLock()
-						if _, ok := s.upch[res.Tag]; ok {
-							s.upch[res.Tag] <- res.Data
-						}
-						s.chlock.RUnlock()
-
-					case "gets":
-						s.chlock.RLock()
-						if _, ok := s.upch[res.Tag]; ok {
-							s.upch[res.Tag] <- res.Data
-						}
-						s.chlock.RUnlock()
+					if !TcpS2s().pending.dispatch(res) {
+						logger.Debug("ReadFromTcp response without request", zap.Any("cmd", res.Cmd), zap.Any("tag", res.Tag))
 					}
 
 					return
 				}
 
 				// s2s服务主动推送的消息
-				// 收到通知重新获取节点信息
+				// 通知带有节点信息时直接更新缓存，否则重新获取节点信息
 				switch res.Code {
-				case "update":
-					s.refresh <- true
-				case "delete":
-					s.refresh <- true
+				case "update", "delete":
+					s.onNotify(res.Data)
 				}
 
-				logger.Debug("ReadFromTcp refresh", zap.Any("size", size), zap.Any("cmd", res.Cmd), zap.Any("code", res.Code))
+				logger.Debug("ReadFromTcp refresh", zap.Any("size", len(data)), zap.Any("cmd", res.Cmd), zap.Any("code", res.Code))
 				return nil
-			}, func(ctx context.Context, conn *net.TCPConn) error {
+			}, func(conn net.Conn) {
 				logger.Warn("s2s connected closed! start retry!")
-				TcpS2s().reset()
-
-				return nil
+				TcpS2s().reset(conn)
 			})
 
 			i++
 			logger.Debug("ReadFromTcp start reconnect!", zap.Any("times", i))
-
-			time.Sleep(2 * time.Second)
-			TcpS2s().Connect()
 		}
-
-		return
 	}()
 }
@@ -0,0 +1,209 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+// 没有监听的地址
+func closedAddr(t *testing.T) string {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	return addr
+}
+
+func Test_CallDeadline(t *testing.T) {
+	cli := &tcpS2s{
+		endpoints: parseEndpoints(closedAddr(t)),
+		pending:   newPending(),
+		backoff: Backoff{
+			Initial:    time.Second,
+			Max:        time.Second,
+			Multiplier: 1,
+			Deadline:   30 * time.Second,
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := cli.Call(ctx, &StreamReq{Cmd: "get", Data: "test.svr"}, 0)
+	if !errors.Is(err, ErrCallTimeout) {
+		t.Fatalf("unexpected err %v", err)
+	}
+	if delay := time.Since(start); time.Second <= delay {
+		t.Fatalf("call ignored ctx deadline, delay %v", delay)
+	}
+	if stats := cli.Stats(); 1 != stats.Timeouts || 0 != stats.Outstanding {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func Test_reconnectAttempts(t *testing.T) {
+	cli := &tcpS2s{
+		endpoints: parseEndpoints(closedAddr(t)),
+		pending:   newPending(),
+		backoff: Backoff{
+			Initial:     time.Second,
+			Max:         time.Second,
+			Multiplier:  1,
+			MaxAttempts: 1,
+		},
+	}
+
+	// 只尝试一次时失败后直接返回，不等待重试间隔
+	start := time.Now()
+	if err := cli.Connect(); nil == err {
+		t.Fatal("connect to closed addr")
+	}
+	if delay := time.Since(start); time.Second <= delay {
+		t.Fatalf("slept after last attempt, delay %v", delay)
+	}
+	if StateDegraded != cli.State() {
+		t.Fatalf("unexpected state %v", cli.State())
+	}
+
+	// 等待重试时不持有connlock
+	cli.backoff.MaxAttempts = 3
+	go cli.Connect()
+	time.Sleep(100 * time.Millisecond)
+
+	done := make(chan bool)
+	go func() {
+		cli.setTLS(nil)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("setTLS blocked by reconnect")
+	}
+}
+
+func Test_resendOnce(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	frames := make(chan *StreamReq, 8)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if nil != err {
+				return
+			}
+
+			go ReadFrames(conn, func(conn net.Conn, data []byte) error {
+				req, _, err := DecodeReq(data)
+				if nil == err {
+					frames <- req
+				}
+
+				return err
+			}, func(conn net.Conn) {})
+		}
+	}()
+
+	cli := &tcpS2s{
+		endpoints: parseEndpoints(ln.Addr().String()),
+		pending:   newPending(),
+		backoff:   DefaultBackoff,
+	}
+	if err := cli.Connect(); nil != err {
+		t.Fatal(err)
+	}
+	defer cli.GetConn().Close()
+
+	req := &StreamReq{Cmd: "get", Data: "test.svr", Tag: "t1"}
+	cli.pending.add(req, nil)
+
+	// 切换连接后resend已经写入，send不再写入
+	cli.resend(cli.GetConn())
+	if err := cli.send(context.Background(), req); nil != err {
+		t.Fatal(err)
+	}
+	cli.resend(cli.GetConn())
+
+	// 不等待响应的请求每次都写入
+	if err := cli.send(context.Background(), &StreamReq{Cmd: "list", Tag: "t2"}); nil != err {
+		t.Fatal(err)
+	}
+
+	tags := make([]string, 0)
+	for len(tags) < 2 {
+		select {
+		case req := <-frames:
+			tags = append(tags, req.Tag)
+		case <-time.After(time.Second):
+			t.Fatalf("unexpected frames %v", tags)
+		}
+	}
+	select {
+	case req := <-frames:
+		t.Fatalf("request written twice %v %v", tags, req.Tag)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if "t1" != tags[0] || "t2" != tags[1] {
+		t.Fatalf("unexpected frames %v", tags)
+	}
+}
+
+func Test_Endpoints(t *testing.T) {
+	cli := &tcpS2s{endpoints: parseEndpoints("127.0.0.1:1, 127.0.0.1:2,127.0.0.1:1")}
+	cli.endpoints[1].setHealthy(false)
+
+	eps := cli.Endpoints()
+	if 2 != len(eps) || !eps["127.0.0.1:1"] || eps["127.0.0.1:2"] {
+		t.Fatalf("unexpected endpoints %v", eps)
+	}
+}
+
+func Test_Frames(t *testing.T) {
+	// 分帧不依赖tcp连接，tls连接同样可以使用
+	client, server := net.Pipe()
+	defer client.Close()
+
+	frames := make(chan string, 2)
+	closed := make(chan bool)
+	go ReadFrames(server, func(conn net.Conn, data []byte) error {
+		frames <- string(data)
+
+		return nil
+	}, func(conn net.Conn) {
+		close(closed)
+	})
+
+	for _, data := range []string{"hello", ""} {
+		if err := WriteFrame(client, []byte(data)); nil != err {
+			t.Fatal(err)
+		}
+		select {
+		case v := <-frames:
+			if data != v {
+				t.Fatalf("unexpected frame %q", v)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("frame not received")
+		}
+	}
+
+	// 帧头中的长度超过限制时断开
+	client.Write([]byte{0xff, 0xff, 0xff, 0xff})
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("bad frame not closed")
+	}
+}
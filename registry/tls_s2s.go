@@ -0,0 +1,265 @@
+package registry
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"go-micro.dev/v4/logger"
+	"go-micro.dev/v4/registry"
+	"go.uber.org/zap"
+)
+
+const (
+	// 建立tls连接的超时时间
+	dialTimeout = 5 * time.Second
+)
+
+type tlsFilesKey struct{}
+
+type tlsFiles struct {
+	cert string
+	key  string
+	ca   string
+}
+
+// 设置连接s2s使用的证书文件，http和tcp都会使用tls
+// 证书文件更新后新建立的连接使用新的证书，不需要重启
+// 没有设置时读取配置中的s2s.tls_cert、s2s.tls_key、s2s.tls_ca
+//
+// @param cert 	客户端证书，为空则不使用客户端证书
+// @param key 	客户端证书的私钥
+// @param ca 	校验s2s证书的ca，为空则使用系统的ca
+// @return Option
+//
+func TLSFiles(cert, key, ca string) registry.Option {
+	return func(o *registry.Options) {
+		if nil == o.Context {
+			o.Context = context.Background()
+		}
+
+		o.Context = context.WithValue(o.Context, tlsFilesKey{}, tlsFiles{cert: cert, key: key, ca: ca})
+	}
+}
+
+// 证书文件，修改时间变化时重新加载
+type certReloader struct {
+	files tlsFiles
+
+	lock    sync.Mutex
+	cert    *tls.Certificate
+	pool    *x509.CertPool
+	certMod time.Time
+	caMod   time.Time
+}
+
+// 文件的修改时间，多个文件取最新的
+//
+// @param files
+// @return time.Time
+//
+func modTime(files ...string) (time.Time, error) {
+	var mod time.Time
+	for _, f := range files {
+		info, err := os.Stat(f)
+		if nil != err {
+			return mod, err
+		}
+
+		if info.ModTime().After(mod) {
+			mod = info.ModTime()
+		}
+	}
+
+	return mod, nil
+}
+
+// 检查证书文件是否有变化，有变化则重新加载
+// 加载失败时继续使用之前的证书，证书轮换时文件可能只写了一半
+//
+// @return {cert,pool,error}
+//
+func (r *certReloader) load() (*tls.Certificate, *x509.CertPool, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if 0 < len(r.files.cert) {
+		mod, err := modTime(r.files.cert, r.files.key)
+		if nil == err && !mod.Equal(r.certMod) {
+			var cert tls.Certificate
+			cert, err = tls.LoadX509KeyPair(r.files.cert, r.files.key)
+			if nil == err {
+				r.cert = &cert
+				r.certMod = mod
+
+				logger.Info("load s2s tls cert", zap.Any("cert", r.files.cert), zap.Any("mod", mod))
+			}
+		}
+		if nil != err {
+			if nil == r.cert {
+				return nil, nil, err
+			}
+
+			logger.Warn("reload s2s tls cert err, use the old one", zap.Any("cert", r.files.cert), zap.Error(err))
+		}
+	}
+
+	if 0 < len(r.files.ca) {
+		mod, err := modTime(r.files.ca)
+		if nil == err && !mod.Equal(r.caMod) {
+			var data []byte
+			data, err = ioutil.ReadFile(r.files.ca)
+			if nil == err {
+				pool := x509.NewCertPool()
+				if pool.AppendCertsFromPEM(data) {
+					r.pool = pool
+					r.caMod = mod
+
+					logger.Info("load s2s tls ca", zap.Any("ca", r.files.ca), zap.Any("mod", mod))
+				} else {
+					err = errors.New("no certificate found in " + r.files.ca)
+				}
+			}
+		}
+		if nil != err {
+			if nil == r.pool {
+				return nil, nil, err
+			}
+
+			logger.Warn("reload s2s tls ca err, use the old one", zap.Any("ca", r.files.ca), zap.Error(err))
+		}
+	}
+
+	return r.cert, r.pool, nil
+}
+
+// 连接s2s使用的tls配置
+type s2sTLS struct {
+	base     *tls.Config
+	reloader *certReloader
+}
+
+// 根据registry的选项和配置创建tls配置，不使用tls时返回nil
+// registry.TLSConfig作为基础配置，证书文件中的证书和ca会覆盖基础配置
+//
+// @param opts
+// @return *s2sTLS
+//
+func newS2sTLS(opts registry.Options) *s2sTLS {
+	var files tlsFiles
+	ok := false
+	if nil != opts.Context {
+		files, ok = opts.Context.Value(tlsFilesKey{}).(tlsFiles)
+	}
+	if !ok {
+		files = tlsFiles{
+			cert: s2sConfig("tls_cert").String(""),
+			key:  s2sConfig("tls_key").String(""),
+			ca:   s2sConfig("tls_ca").String(""),
+		}
+	}
+
+	hasFiles := 0 < len(files.cert) || 0 < len(files.ca)
+	if nil == opts.TLSConfig && !hasFiles {
+		return nil
+	}
+
+	t := &s2sTLS{
+		base: opts.TLSConfig,
+	}
+	if nil == t.base {
+		t.base = &tls.Config{
+			ServerName: s2sConfig("tls_server_name").String(""),
+		}
+	}
+	if hasFiles {
+		t.reloader = &certReloader{files: files}
+	}
+
+	return t
+}
+
+// 每次建立连接时生成新的配置，这样可以使用更新后的证书
+//
+// @return {*tls.Config,error}
+//
+func (t *s2sTLS) config() (*tls.Config, error) {
+	cfg := t.base.Clone()
+	if nil == t.reloader {
+		return cfg, nil
+	}
+
+	cert, pool, err := t.reloader.load()
+	if nil != err {
+		return nil, err
+	}
+	if nil != cert {
+		cfg.Certificates = []tls.Certificate{*cert}
+	}
+	if nil != pool {
+		cfg.RootCAs = pool
+	}
+
+	return cfg, nil
+}
+
+// 建立tls连接并完成握手
+//
+// @param ctx
+// @param network
+// @param addr
+// @return {net.Conn,error}
+//
+func (t *s2sTLS) dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	cfg, err := t.config()
+	if nil != err {
+		return nil, err
+	}
+	if 0 == len(cfg.ServerName) {
+		host, _, err := net.SplitHostPort(addr)
+		if nil != err {
+			return nil, err
+		}
+
+		cfg.ServerName = host
+	}
+
+	dialer := &net.Dialer{Timeout: dialTimeout}
+	raw, err := dialer.DialContext(ctx, network, addr)
+	if nil != err {
+		return nil, err
+	}
+
+	conn := tls.Client(raw, cfg)
+	err = conn.HandshakeContext(ctx)
+	if nil != err {
+		raw.Close()
+
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+// 访问s2s http接口的客户端，使用tls时每个连接都重新读取证书
+//
+// @param t
+// @return *http.Client
+//
+func newHttpClient(t *s2sTLS) *http.Client {
+	if nil == t {
+		return http.DefaultClient
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialTLSContext = t.dial
+
+	return &http.Client{Transport: transport}
+}
@@ -0,0 +1,267 @@
+package registry
+
+import (
+	"errors"
+	"time"
+
+	"go-micro.dev/v4/logger"
+	"go-micro.dev/v4/registry"
+	"go.uber.org/zap"
+)
+
+var errNoRegistry = errors.New("s2s registry not created")
+
+// 检查服务是否可以注册
+// 进程退出中或者服务通过Leave暂时离开时，go-micro定时的注册会被忽略
+//
+// @param svc
+// @return bool
+//
+func (s *proxy) allowRegister(svc *registry.Service) bool {
+	s.llock.Lock()
+	defer s.llock.Unlock()
+
+	if s.stopping {
+		return false
+	}
+
+	l, ok := s.locals[localKey(svc)]
+	return !ok || !l.held
+}
+
+// 按服务名查找本地服务，name为空时返回所有服务
+//
+// @param name
+// @return {[]localService}
+//
+func (s *proxy) findLocals(name string) []*localService {
+	s.llock.Lock()
+	defer s.llock.Unlock()
+
+	locals := make([]*localService, 0, len(s.locals))
+	for _, l := range s.locals {
+		if 0 < len(name) && name != l.name {
+			continue
+		}
+
+		locals = append(locals, l)
+	}
+
+	return locals
+}
+
+// 查找已经记录的本地服务
+//
+// @param svc
+// @return *localService 	没有注册过时返回nil
+//
+func (s *proxy) findLocal(svc *registry.Service) *localService {
+	s.llock.Lock()
+	defer s.llock.Unlock()
+
+	return s.locals[localKey(svc)]
+}
+
+func (s *proxy) setHeld(l *localService, held bool) {
+	s.llock.Lock()
+	l.held = held
+	s.llock.Unlock()
+}
+
+// 本地服务的注册信息，Register会替换
+//
+// @param l
+// @return {svc,ttl,held}
+//
+func (s *proxy) localInfo(l *localService) (*registry.Service, time.Duration, bool) {
+	s.llock.Lock()
+	defer s.llock.Unlock()
+
+	return l.svc, l.ttl, l.held || s.stopping
+}
+
+// 发送本地服务的注册信息，和Leave、Rejoin、Shutdown互斥
+// 服务暂时离开或者进程退出中时不发送
+//
+// @param l
+// @param first
+// @return error
+//
+func (s *proxy) registerLocal(l *localService, first bool) error {
+	l.send.Lock()
+	defer l.send.Unlock()
+
+	svc, ttl, held := s.localInfo(l)
+	if held {
+		return nil
+	}
+
+	return s.register(svc, ttl, first)
+}
+
+// 本进程注册的所有服务，包括暂时离开的服务
+//
+// @return {[]Service}
+//
+func LocalServices() []*registry.Service {
+	if nil == gs {
+		return nil
+	}
+
+	services := make([]*registry.Service, 0)
+	for _, l := range gs.findLocals("") {
+		svc, _, _ := gs.localInfo(l)
+		services = append(services, svc)
+	}
+
+	return services
+}
+
+// 服务暂时离开，从s2s注销但是保留注册信息，期间go-micro定时的注册被忽略
+// 调用Rejoin后重新注册
+//
+// @param name 	服务名，为空时所有服务都离开
+// @return error
+//
+func Leave(name string) error {
+	if nil == gs {
+		return errNoRegistry
+	}
+
+	var gerr error
+	for _, l := range gs.findLocals(name) {
+		err := gs.leave(l)
+		if nil != err {
+			logger.Warn("leave s2s err", zap.Any("name", l.name), zap.Error(err))
+
+			gerr = err
+			continue
+		}
+
+		logger.Info("leave s2s", zap.Any("name", l.name))
+	}
+
+	return gerr
+}
+
+// 标记离开后注销，等待正在发送的心跳完成，避免注销后又被注册
+//
+// @param l
+// @return error
+//
+func (s *proxy) leave(l *localService) error {
+	l.send.Lock()
+	defer l.send.Unlock()
+
+	s.setHeld(l, true)
+	svc, _, _ := s.localInfo(l)
+
+	return s.deregister(svc)
+}
+
+// 重新注册暂时离开的服务
+//
+// @param l
+// @return {rejoined,error}
+//
+func (s *proxy) rejoin(l *localService) (bool, error) {
+	l.send.Lock()
+	defer l.send.Unlock()
+
+	s.llock.Lock()
+	svc, ttl, held := l.svc, l.ttl, l.held
+	s.llock.Unlock()
+	if !held {
+		return false, nil
+	}
+
+	err := s.register(svc, ttl, false)
+	if nil != err {
+		return false, err
+	}
+
+	s.setHeld(l, false)
+	return true, nil
+}
+
+// 暂时离开的服务重新注册到s2s
+//
+// @param name 	服务名，为空时所有服务都重新注册
+// @return error
+//
+func Rejoin(name string) error {
+	if nil == gs {
+		return errNoRegistry
+	}
+
+	var gerr error
+	for _, l := range gs.findLocals(name) {
+		ok, err := gs.rejoin(l)
+		if nil != err {
+			logger.Warn("rejoin s2s err", zap.Any("name", l.name), zap.Error(err))
+
+			gerr = err
+			continue
+		}
+
+		if ok {
+			logger.Info("rejoin s2s", zap.Any("name", l.name))
+		}
+	}
+
+	return gerr
+}
+
+// 进程退出前注销所有服务，之后的注册都会被忽略
+// 多个服务的BeforeStop都可以调用，只有第一次会注销
+//
+// @return error
+//
+func Shutdown() error {
+	if nil == gs {
+		return nil
+	}
+
+	gs.llock.Lock()
+	if gs.stopping {
+		gs.llock.Unlock()
+
+		return nil
+	}
+	gs.stopping = true
+	gs.llock.Unlock()
+
+	var gerr error
+	for _, l := range gs.findLocals("") {
+		err := gs.shutdown(l)
+		if nil != err {
+			logger.Warn("deregister on shutdown err", zap.Any("name", l.name), zap.Error(err))
+
+			gerr = err
+			continue
+		}
+
+		logger.Info("deregister on shutdown", zap.Any("name", l.name))
+	}
+
+	return gerr
+}
+
+// 进程退出时注销服务，等待正在发送的心跳完成
+//
+// @param l
+// @return error
+//
+func (s *proxy) shutdown(l *localService) error {
+	l.send.Lock()
+	defer l.send.Unlock()
+
+	svc, _, _ := s.localInfo(l)
+	err := s.deregister(svc)
+	if nil != err {
+		return err
+	}
+
+	s.untrack(svc)
+	return nil
+}
@@ -0,0 +1,107 @@
+package registry
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go-micro.dev/v4/registry"
+)
+
+func Test_Tracker(t *testing.T) {
+	s := &proxy{locals: make(map[string]*localService)}
+	svc := &registry.Service{Name: "test.svr", Nodes: []*registry.Node{{Id: "n1"}}}
+
+	s.track(svc, 30*time.Second)
+	if !s.allowRegister(svc) || 1 != len(s.dueLocals(true)) {
+		t.Fatal("tracked service not registered")
+	}
+
+	l := s.findLocals("test.svr")[0]
+	s.setHeld(l, true)
+	if s.allowRegister(svc) || 0 != len(s.dueLocals(true)) {
+		t.Fatal("held service registered")
+	}
+
+	// go-micro定时的Register不会让离开的服务重新加入
+	s.track(svc, 30*time.Second)
+	if s.allowRegister(svc) || 0 != len(s.dueLocals(true)) {
+		t.Fatal("held service rejoined by register")
+	}
+
+	// 注销后可以重新注册
+	s.untrack(svc)
+	if !s.allowRegister(svc) {
+		t.Fatal("deregistered service can not register again")
+	}
+
+	s.track(svc, 30*time.Second)
+	s.stopping = true
+	if s.allowRegister(svc) || 0 != len(s.dueLocals(true)) {
+		t.Fatal("service registered after shutdown")
+	}
+}
+
+func Test_LeaveDuringHeartbeat(t *testing.T) {
+	var lock sync.Mutex
+	methods := make([]string, 0)
+	started := make(chan bool, 1)
+	release := make(chan bool)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		methods = append(methods, r.Method)
+		first := 1 == len(methods)
+		lock.Unlock()
+
+		// 第一个心跳等待Leave开始后再返回
+		if first {
+			started <- true
+			<-release
+		}
+	}))
+	defer server.Close()
+
+	s := &proxy{
+		opts:   registry.Options{Addrs: []string{strings.TrimPrefix(server.URL, "http://")}},
+		client: http.DefaultClient,
+		locals: make(map[string]*localService),
+	}
+	svc := &registry.Service{Name: "test.svr", Nodes: []*registry.Node{{Id: "n1"}}}
+	l := s.track(svc, 30*time.Second)
+
+	go s.registerLocal(l, false)
+	<-started
+
+	left := make(chan error, 1)
+	go func() {
+		left <- s.leave(l)
+	}()
+
+	// 心跳完成之前不注销
+	select {
+	case err := <-left:
+		t.Fatalf("left during heartbeat %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	if err := <-left; nil != err {
+		t.Fatal(err)
+	}
+
+	// 离开后的心跳和go-micro定时的注册都不发送
+	if err := s.registerLocal(l, false); nil != err {
+		t.Fatal(err)
+	}
+	if err := s.Register(svc, registry.RegisterTTL(30*time.Second)); nil != err {
+		t.Fatal(err)
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+	if "POST,DELETE" != strings.Join(methods, ",") {
+		t.Fatalf("unexpected requests %v", methods)
+	}
+}
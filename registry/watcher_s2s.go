@@ -2,84 +2,49 @@ package registry
 
 import (
 	"errors"
-	//	"net/http"
-	"time"
+	"reflect"
+	"sync/atomic"
 
-	"github.com/gorilla/websocket"
-	log "go-micro.dev/v4/logger"
 	"go-micro.dev/v4/registry"
 )
 
+// watcher处理不及时丢弃了事件，调用方需要重新获取全部节点
+var ErrWatchOverflow = errors.New("s2s watcher overflow, resync required")
+
 const (
-	pingTime      = (readDeadline * 9) / 10
-	readLimit     = 16384
-	readDeadline  = 60 * time.Second
-	writeDeadline = 10 * time.Second
+	// 每个watcher缓存的事件数量
+	watchBuffer = 64
 )
 
 type watcher struct {
+	id   string
+	wo   registry.WatchOptions
 	exit chan bool
-	conn *websocket.Conn
 	res  chan *registry.Result
-}
+	// 缓存满时丢弃了事件，为1时Next返回ErrWatchOverflow
+	overflow int32
 
-func (w *watcher) ping() {
-	ticker := time.NewTicker(pingTime)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ticker.C:
-			w.conn.SetWriteDeadline(time.Now().Add(writeDeadline))
-			err := w.conn.WriteMessage(websocket.PingMessage, []byte{})
-			if err != nil {
-				log.Errorf("watcher error writing ping message: %v", err)
-				return
-			}
-		case <-w.exit:
-			return
-		}
-	}
+	// 停止时从proxy中移除自己
+	stop func(id string)
 }
 
-func (w *watcher) run() {
-	// set read limit/deadline
-	w.conn.SetReadLimit(readLimit)
-	w.conn.SetReadDeadline(time.Now().Add(readDeadline))
-
-	// set close handler
-	ch := w.conn.CloseHandler()
-	w.conn.SetCloseHandler(func(code int, text string) error {
-		err := ch(code, text)
-		w.Stop()
-		return err
-	})
-
-	// set pong handler
-	w.conn.SetPongHandler(func(string) error {
-		w.conn.SetReadDeadline(time.Now().Add(readDeadline))
-		return nil
-	})
-
-	// read results and send to res channel
-	for {
-		var res *registry.Result
-		if err := w.conn.ReadJSON(&res); err != nil {
-			log.Errorf("error unmarshaling result: %v", err)
-			return
-		}
-		select {
-		case w.res <- res:
-		case <-w.exit:
-			return
+func (w *watcher) Next() (*registry.Result, error) {
+	// 先取完缓存的事件，再通知调用方重新同步
+	select {
+	case r, ok := <-w.res:
+		if !ok {
+			return nil, errors.New("result chan stopped")
 		}
+		return r, nil
+	default:
+	}
+	if atomic.CompareAndSwapInt32(&w.overflow, 1, 0) {
+		return nil, ErrWatchOverflow
 	}
-}
 
-func (w *watcher) Next() (*registry.Result, error) {
 	select {
 	case <-w.exit:
-		return nil, errors.New("result chan stopped")
+		return nil, registry.ErrWatcherStopped
 	case r, ok := <-w.res:
 		if !ok {
 			return nil, errors.New("result chan stopped")
@@ -94,20 +59,135 @@ func (w *watcher) Stop() {
 		return
 	default:
 		close(w.exit)
+		if nil != w.stop {
+			w.stop(w.id)
+		}
+	}
+}
+
+// 推送事件给watcher，只推送关注的服务
+// 不会阻塞，缓存满时丢弃事件并标记watcher需要重新同步
+//
+// @param res 	事件
+//
+func (w *watcher) notify(res *registry.Result) {
+	if 0 < len(w.wo.Service) && w.wo.Service != res.Service.Name {
+		return
+	}
+
+	select {
+	case <-w.exit:
+		return
+	default:
+	}
+
+	select {
+	case w.res <- res:
+	default:
+		atomic.StoreInt32(&w.overflow, 1)
 	}
 }
 
-func newWatcher(url string) (registry.Watcher, error) {
-	//	conn, _, err := websocket.DefaultDialer.Dial(url, make(http.Header))
-	//	if err != nil {
-	//		return nil, err
-	//	}
-	w := &watcher{
-		//		conn: conn,
+func newWatcher(id string, wo registry.WatchOptions, stop func(id string)) *watcher {
+	return &watcher{
+		id:   id,
+		wo:   wo,
+		stop: stop,
 		exit: make(chan bool),
-		res:  make(chan *registry.Result),
+		res:  make(chan *registry.Result, watchBuffer),
+	}
+}
+
+// 将事件广播给所有的watcher
+//
+// @param results 	事件列表
+//
+func (s *proxy) broadcast(results []*registry.Result) {
+	if 0 == len(results) {
+		return
+	}
+
+	s.wlock.RLock()
+	watchers := make([]*watcher, 0, len(s.watchers))
+	for _, w := range s.watchers {
+		watchers = append(watchers, w)
+	}
+	s.wlock.RUnlock()
+
+	// 被默认过滤条件排除的节点转换为delete事件，包括正在下线的节点
+	// 每个watcher使用单独的副本，不会和缓存共用节点
+	filters := s.defaultFilters()
+	for _, v := range results {
+		for _, res := range filterResult(v, filters) {
+			for _, w := range watchers {
+				w.notify(&registry.Result{Action: res.Action, Service: copyService(res.Service)})
+			}
+		}
+	}
+}
+
+// 比较服务新旧节点信息，生成对应的事件
+// 新增的版本为create，节点有变化为update，移除的节点为delete
+//
+// @param old 	旧的服务列表
+// @param cur 	新的服务列表
+// @return {[]Result}
+//
+func diffServices(old, cur []*registry.Service) []*registry.Result {
+	results := make([]*registry.Result, 0)
+
+	oldVer := make(map[string]*registry.Service)
+	for _, v := range old {
+		oldVer[v.Version] = v
+	}
+	curVer := make(map[string]*registry.Service)
+	for _, v := range cur {
+		curVer[v.Version] = v
+	}
+
+	for ver, svc := range curVer {
+		prev, ok := oldVer[ver]
+		if !ok {
+			results = append(results, &registry.Result{Action: "create", Service: svc})
+			continue
+		}
+
+		prevNodes := make(map[string]*registry.Node)
+		for _, n := range prev.Nodes {
+			prevNodes[n.Id] = n
+		}
+
+		changed := false
+		for _, n := range svc.Nodes {
+			pn, ok := prevNodes[n.Id]
+			if !ok || pn.Address != n.Address || !reflect.DeepEqual(pn.Metadata, n.Metadata) {
+				changed = true
+			}
+			delete(prevNodes, n.Id)
+		}
+		if changed {
+			results = append(results, &registry.Result{Action: "update", Service: svc})
+		}
+
+		if 0 < len(prevNodes) {
+			removed := *prev
+			removed.Nodes = make([]*registry.Node, 0, len(prevNodes))
+			for _, n := range prev.Nodes {
+				if _, ok := prevNodes[n.Id]; ok {
+					removed.Nodes = append(removed.Nodes, n)
+				}
+			}
+			results = append(results, &registry.Result{Action: "delete", Service: &removed})
+		}
+	}
+
+	for ver, svc := range oldVer {
+		if _, ok := curVer[ver]; ok {
+			continue
+		}
+
+		results = append(results, &registry.Result{Action: "delete", Service: svc})
 	}
-	//	go w.ping()
-	//	go w.run()
-	return w, nil
+
+	return results
 }
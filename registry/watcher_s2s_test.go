@@ -0,0 +1,74 @@
+package registry
+
+import (
+	"testing"
+
+	"go-micro.dev/v4/registry"
+)
+
+func Test_diffServices(t *testing.T) {
+	node := func(id, addr string) *registry.Node {
+		return &registry.Node{Id: id, Address: addr}
+	}
+	svc := func(ver string, nodes ...*registry.Node) *registry.Service {
+		return &registry.Service{Name: "test.svr", Version: ver, Nodes: nodes}
+	}
+
+	cases := []struct {
+		name    string
+		old     []*registry.Service
+		cur     []*registry.Service
+		actions map[string]int
+	}{
+		{"empty", nil, nil, map[string]int{}},
+		{"create", nil, []*registry.Service{svc("1", node("a", "1:1"))}, map[string]int{"create": 1}},
+		{"delete version", []*registry.Service{svc("1", node("a", "1:1"))}, nil, map[string]int{"delete": 1}},
+		{"same", []*registry.Service{svc("1", node("a", "1:1"))}, []*registry.Service{svc("1", node("a", "1:1"))}, map[string]int{}},
+		{"address changed", []*registry.Service{svc("1", node("a", "1:1"))}, []*registry.Service{svc("1", node("a", "1:2"))}, map[string]int{"update": 1}},
+		{"node added", []*registry.Service{svc("1", node("a", "1:1"))}, []*registry.Service{svc("1", node("a", "1:1"), node("b", "1:2"))}, map[string]int{"update": 1}},
+		{"node removed", []*registry.Service{svc("1", node("a", "1:1"), node("b", "1:2"))}, []*registry.Service{svc("1", node("a", "1:1"))}, map[string]int{"delete": 1}},
+		{"new version", []*registry.Service{svc("1", node("a", "1:1"))}, []*registry.Service{svc("2", node("b", "1:2"))}, map[string]int{"create": 1, "delete": 1}},
+	}
+
+	for _, c := range cases {
+		actions := make(map[string]int)
+		for _, res := range diffServices(c.old, c.cur) {
+			actions[res.Action]++
+		}
+
+		if len(actions) != len(c.actions) {
+			t.Errorf("%s: unexpected actions %v", c.name, actions)
+			continue
+		}
+		for k, v := range c.actions {
+			if actions[k] != v {
+				t.Errorf("%s: unexpected actions %v", c.name, actions)
+			}
+		}
+	}
+
+	// 只有被移除的节点出现在delete事件中
+	res := diffServices([]*registry.Service{svc("1", node("a", "1:1"), node("b", "1:2"))}, []*registry.Service{svc("1", node("a", "1:1"))})
+	if 1 != len(res) || 1 != len(res[0].Service.Nodes) || "b" != res[0].Service.Nodes[0].Id {
+		t.Fatalf("unexpected delete result %v", res)
+	}
+}
+
+func Test_watcherOverflow(t *testing.T) {
+	w := newWatcher("test", registry.WatchOptions{}, nil)
+	defer w.Stop()
+
+	// 没有读取的watcher不会阻塞推送
+	for i := 0; i < watchBuffer+10; i++ {
+		w.notify(&registry.Result{Action: "update", Service: &registry.Service{Name: "test.svr"}})
+	}
+
+	for i := 0; i < watchBuffer; i++ {
+		if _, err := w.Next(); nil != err {
+			t.Fatal(err)
+		}
+	}
+	if _, err := w.Next(); ErrWatchOverflow != err {
+		t.Fatalf("expected overflow, got %v", err)
+	}
+}
@@ -0,0 +1,235 @@
+package registry
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"go-micro.dev/v4/logger"
+	"go.uber.org/zap"
+)
+
+type watchEntry struct {
+	// Subscribe的次数，大于0时不会被淘汰
+	refs int
+	// GetService最后一次使用的时间
+	lastUsed time.Time
+}
+
+// 需要定时刷新的服务列表
+// GetService使用的服务会被隐式加入，长时间不用或者超过数量上限时被淘汰
+// Subscribe加入的服务在Unsubscribe之前一直刷新
+type WatchNode struct {
+	nodes map[string]*watchEntry
+
+	lock sync.RWMutex
+}
+
+// GetService使用的服务，记录最后使用的时间
+//
+// @param obj 	服务名
+//
+func (w *WatchNode) Add(obj string) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.entry(obj).lastUsed = time.Now()
+}
+
+// 显式订阅服务，需要调用同样次数的Unsubscribe取消
+//
+// @param obj 	服务名
+//
+func (w *WatchNode) Subscribe(obj string) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	e := w.entry(obj)
+	e.refs++
+	e.lastUsed = time.Now()
+}
+
+// 取消订阅，所有订阅都取消后不再刷新这个服务
+//
+// @param obj 	服务名
+// @return bool 	是否已经不再刷新
+//
+func (w *WatchNode) Unsubscribe(obj string) bool {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	e, ok := w.nodes[obj]
+	if !ok {
+		return true
+	}
+
+	e.refs--
+	if 0 < e.refs {
+		return false
+	}
+
+	delete(w.nodes, obj)
+	return true
+}
+
+func (w *WatchNode) entry(obj string) *watchEntry {
+	if nil == w.nodes {
+		w.nodes = make(map[string]*watchEntry)
+	}
+
+	e, ok := w.nodes[obj]
+	if !ok {
+		e = &watchEntry{}
+		w.nodes[obj] = e
+	}
+
+	return e
+}
+
+// 检查服务是否需要刷新
+//
+// @param obj
+// @return bool
+//
+func (w *WatchNode) Has(obj string) bool {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+
+	_, ok := w.nodes[obj]
+	return ok
+}
+
+// 所有需要刷新的服务，返回排序后的副本
+//
+// @return []string
+//
+func (w *WatchNode) Nodes() []string {
+	w.lock.RLock()
+	defer w.lock.RUnlock()
+
+	nodes := make([]string, 0, len(w.nodes))
+	for k := range w.nodes {
+		nodes = append(nodes, k)
+	}
+	sort.Strings(nodes)
+
+	return nodes
+}
+
+// 淘汰隐式加入的服务，先淘汰超过idle没有使用的，再按最后使用时间淘汰超过max的部分
+//
+// @param idle 	小于等于0时不按时间淘汰
+// @param max 	小于等于0时不限制数量
+// @return []string 	被淘汰的服务
+//
+func (w *WatchNode) Evict(idle time.Duration, max int) []string {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	now := time.Now()
+	evicted := make([]string, 0)
+	implicit := make([]string, 0, len(w.nodes))
+	for k, e := range w.nodes {
+		if 0 < e.refs {
+			continue
+		}
+
+		if 0 < idle && now.Sub(e.lastUsed) > idle {
+			delete(w.nodes, k)
+			evicted = append(evicted, k)
+
+			continue
+		}
+
+		implicit = append(implicit, k)
+	}
+
+	if 0 < max && max < len(implicit) {
+		sort.Slice(implicit, func(i, j int) bool {
+			return w.nodes[implicit[i]].lastUsed.Before(w.nodes[implicit[j]].lastUsed)
+		})
+
+		for _, k := range implicit[:len(implicit)-max] {
+			delete(w.nodes, k)
+			evicted = append(evicted, k)
+		}
+	}
+
+	return evicted
+}
+
+var watchNode WatchNode
+
+// 设置要监听的节点连接信息，配置中的服务一直刷新
+//
+// @param nodes 配置中的nodes项
+//
+func SetWatchNode(nodes []string) {
+	Subscribe(nodes...)
+}
+
+// 订阅服务，一直刷新服务的节点信息，直到取消订阅
+//
+// @param names
+//
+func Subscribe(names ...string) {
+	for _, v := range names {
+		if 0 == len(v) {
+			continue
+		}
+
+		watchNode.Subscribe(v)
+	}
+}
+
+// 取消订阅服务，不再刷新的服务从缓存中删除
+//
+// @param names
+//
+func Unsubscribe(names ...string) {
+	removed := make([]string, 0, len(names))
+	for _, v := range names {
+		if watchNode.Unsubscribe(v) {
+			removed = append(removed, v)
+		}
+	}
+
+	if nil != gs {
+		gs.dropCache(removed)
+	}
+}
+
+// 删除不再刷新的服务的缓存，避免使用过期的节点
+//
+// @param names
+//
+func (s *proxy) dropCache(names []string) {
+	if 0 == len(names) {
+		return
+	}
+
+	ns := s.namespace()
+
+	s.rwlock.Lock()
+	defer s.rwlock.Unlock()
+
+	for _, name := range names {
+		delete(s.svrs, cacheKey(ns, name))
+	}
+}
+
+// 淘汰长时间没有使用的服务
+// 读取配置中的s2s.watch_idle，单位秒，默认3600，以及s2s.watch_max，默认1000
+//
+func (s *proxy) evictWatch() {
+	idle := s2sConfig("watch_idle").Int(3600)
+	max := s2sConfig("watch_max").Int(1000)
+
+	evicted := watchNode.Evict(time.Duration(idle)*time.Second, max)
+	if 0 == len(evicted) {
+		return
+	}
+
+	logger.Info("evict watched services", zap.Any("names", evicted))
+	s.dropCache(evicted)
+}
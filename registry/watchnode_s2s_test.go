@@ -0,0 +1,27 @@
+package registry
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_WatchNodeEvict(t *testing.T) {
+	var w WatchNode
+	w.Subscribe("sub.svr")
+	w.Add("old.svr")
+	w.nodes["old.svr"].lastUsed = time.Now().Add(-2 * time.Hour)
+	w.Add("a.svr")
+	w.Add("b.svr")
+
+	evicted := w.Evict(time.Hour, 1)
+	if 2 != len(evicted) || w.Has("old.svr") || w.Has("a.svr") {
+		t.Fatalf("unexpected evicted %v", evicted)
+	}
+	if !w.Has("sub.svr") || !w.Has("b.svr") {
+		t.Fatalf("unexpected nodes %v", w.Nodes())
+	}
+
+	if !w.Unsubscribe("sub.svr") || w.Has("sub.svr") {
+		t.Fatal("unsubscribe failed")
+	}
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	foot "github.com/heegspace/heegrpc/callfoot"
+	"github.com/heegspace/heegrpc/conf"
+	"go-micro.dev/v4/client"
+	"go-micro.dev/v4/logger"
+)
+
+var (
+	footLock     sync.Mutex
+	footReporter *foot.Reporter
+	// 服务都停止后等待发送剩余数据的时间，创建上报对象时读取
+	footCloseTimeout time.Duration
+	// 使用上报对象的服务数，最后一个服务停止时关闭
+	footRefs int
+)
+
+// 服务使用调用数据的上报对象，进程内的服务共用一个，没有时创建
+// 读取配置中的statis.queue_size、statis.batch_size、statis.flush_interval（毫秒）和statis.close_timeout（秒）
+// 服务停止后调用releaseFoot
+//
+// @param p 	创建时使用的配置来源，上报对象和它的客户端使用第一个开启上报的服务的配置
+// @return *foot.Reporter
+//
+func acquireFoot(p conf.Provider) *foot.Reporter {
+	footLock.Lock()
+	defer footLock.Unlock()
+
+	footRefs++
+	if nil == footReporter {
+		footCloseTimeout = time.Duration(p.Get("statis", "close_timeout").Int(3)) * time.Second
+
+		// 所有批次共用一个客户端
+		cli := HttpClientWith(p)
+		svrname := p.Get("statis", "svrname").String("footnode")
+		method := p.Get("statis", "batchmethod").String("/foot/rpcbatch")
+		timeout := time.Duration(p.Get("statis", "timeout").Int(3)) * time.Second
+
+		footReporter = foot.NewReporter(func(batch *foot.RPCFootBatchReq) error {
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			var res foot.RPCFootBatchRes
+			req := cli.NewRequest(svrname, method, batch, client.WithContentType("application/proto"))
+			err := cli.Call(ctx, req, &res)
+			if nil != err {
+				logger.Warnf("[Foot Reporter] report %d calls err: %v, dropped: %d", len(batch.Reqs), err, batch.Dropped)
+			}
+
+			return err
+		}, foot.ReporterOptions{
+			QueueSize:     p.Get("statis", "queue_size").Int(foot.DefaultQueueSize),
+			BatchSize:     p.Get("statis", "batch_size").Int(foot.DefaultBatchSize),
+			FlushInterval: time.Duration(p.Get("statis", "flush_interval").Int(1000)) * time.Millisecond,
+		})
+	}
+
+	return footReporter
+}
+
+// 正在使用的上报对象，没有服务使用时返回nil
+//
+// @return *foot.Reporter
+//
+func currentReporter() *foot.Reporter {
+	footLock.Lock()
+	defer footLock.Unlock()
+
+	return footReporter
+}
+
+// 上报一次调用，不会阻塞调用方
+// 所有服务都停止后不再上报
+//
+// @param req
+//
+func reportFoot(req *foot.RPCFootReq) {
+	r := currentReporter()
+	if nil == r {
+		return
+	}
+
+	r.Report(req)
+}
+
+// 服务停止后释放上报对象，最后一个服务停止时发送队列中剩余的数据
+// 最多等待创建时读取的statis.close_timeout秒，默认3
+//
+// @return error
+//
+func releaseFoot() error {
+	footLock.Lock()
+	if 0 < footRefs {
+		footRefs--
+	}
+	if 0 < footRefs || nil == footReporter {
+		footLock.Unlock()
+
+		return nil
+	}
+
+	// 之后启动的服务重新创建上报对象
+	r, timeout := footReporter, footCloseTimeout
+	footReporter = nil
+	footLock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := r.Close(ctx)
+	stats := r.Stats()
+	logger.Infof("[Foot Reporter] closed, sent: %d, failed: %d, dropped: %d, queued: %d, err: %v", stats.Sent, stats.Failed, stats.Dropped, stats.Queued, err)
+
+	return err
+}
+
+// 调用数据上报的统计
+//
+// @return foot.ReporterStats
+//
+func FootStats() foot.ReporterStats {
+	r := currentReporter()
+	if nil == r {
+		return foot.ReporterStats{}
+	}
+
+	return r.Stats()
+}
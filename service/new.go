@@ -0,0 +1,397 @@
+package service
+
+import (
+	"time"
+
+	hystrixsrc "github.com/afex/hystrix-go/hystrix"
+	"github.com/asim/go-micro/plugins/wrapper/breaker/hystrix/v4"
+	"github.com/gin-gonic/gin"
+	"github.com/heegspace/heegrpc/conf"
+	"github.com/juju/ratelimit"
+	"github.com/micro/go-micro/v2/config"
+	"go-micro.dev/v4"
+	"go-micro.dev/v4/client"
+	"go-micro.dev/v4/registry"
+	"go-micro.dev/v4/server"
+	"go-micro.dev/v4/transport"
+
+	httpServer "github.com/asim/go-micro/plugins/server/http/v4"
+	grpc "github.com/asim/go-micro/plugins/transport/grpc/v4"
+	ratelimiter "github.com/asim/go-micro/plugins/wrapper/ratelimiter/ratelimit/v4"
+)
+
+type Options struct {
+	// 是否上报调用统计，默认上报
+	Metrics bool
+	// rpc服务使用的传输层，默认grpc
+	Transport transport.Transport
+	// 不为nil时创建http服务
+	Router *gin.Engine
+	// 为nil时按配置创建s2s或者静态注册中心
+	Registry registry.Registry
+	// 读取服务和s2s配置的来源，为nil时使用conf.Default()
+	Config conf.Provider
+
+	ClientWrappers  []client.Wrapper
+	CallWrappers    []client.CallWrapper
+	HandlerWrappers []server.HandlerWrapper
+
+	BeforeStart []func() error
+	AfterStart  []func() error
+	BeforeStop  []func() error
+	AfterStop   []func() error
+
+	// 每秒处理的请求数和桶的容量，小于等于0时读取配置中的rate
+	Rate     float64
+	Capacity int64
+	// 熔断的超时时间，小于等于0时读取配置中的timeout
+	Timeout time.Duration
+
+	// 注册的节点过期时间和重新注册的间隔，小于等于0时读取配置中的s2s.register_ttl和s2s.register_interval
+	// 都没有设置时使用go-micro的默认值
+	RegisterTTL      time.Duration
+	RegisterInterval time.Duration
+
+	// 其他go-micro选项，最后设置
+	MicroOptions []micro.Option
+}
+
+type Option func(*Options)
+
+// 读取服务的配置，name、version等通常写在服务自己的配置文件中
+// 先读取LoadConf加载的本地配置，没有时读取配置来源，公共配置不会覆盖服务自己的设置
+//
+// @param p
+// @param def
+// @param keys
+// @return string
+//
+func confString(p conf.Provider, def string, keys ...string) string {
+	if v := config.Get(keys...).String(""); 0 < len(v) {
+		return v
+	}
+
+	return p.Get(keys...).String(def)
+}
+
+// 是否上报调用统计
+//
+// @param enable
+//
+func Metrics(enable bool) Option {
+	return func(o *Options) {
+		o.Metrics = enable
+	}
+}
+
+// 设置rpc服务的传输层
+//
+// @param t
+//
+func Transport(t transport.Transport) Option {
+	return func(o *Options) {
+		o.Transport = t
+	}
+}
+
+// 创建http服务，使用gin处理请求
+//
+// @param router
+//
+func Router(router *gin.Engine) Option {
+	return func(o *Options) {
+		o.Router = router
+	}
+}
+
+// 使用指定的注册中心
+//
+// @param r
+//
+func Registry(r registry.Registry) Option {
+	return func(o *Options) {
+		o.Registry = r
+	}
+}
+
+// 设置读取配置的来源，注册中心也使用这个配置
+//
+// @param p
+//
+func Config(p conf.Provider) Option {
+	return func(o *Options) {
+		o.Config = p
+	}
+}
+
+// 添加客户端中间件
+//
+// @param w
+//
+func WrapClient(w ...client.Wrapper) Option {
+	return func(o *Options) {
+		o.ClientWrappers = append(o.ClientWrappers, w...)
+	}
+}
+
+// 添加客户端调用中间件
+//
+// @param w
+//
+func WrapCall(w ...client.CallWrapper) Option {
+	return func(o *Options) {
+		o.CallWrappers = append(o.CallWrappers, w...)
+	}
+}
+
+// 添加服务端中间件
+//
+// @param w
+//
+func WrapHandler(w ...server.HandlerWrapper) Option {
+	return func(o *Options) {
+		o.HandlerWrappers = append(o.HandlerWrappers, w...)
+	}
+}
+
+// 服务启动前调用
+//
+// @param fn
+//
+func BeforeStart(fn func() error) Option {
+	return func(o *Options) {
+		o.BeforeStart = append(o.BeforeStart, fn)
+	}
+}
+
+// 服务启动后调用
+//
+// @param fn
+//
+func AfterStart(fn func() error) Option {
+	return func(o *Options) {
+		o.AfterStart = append(o.AfterStart, fn)
+	}
+}
+
+// 服务停止前调用，在节点下线和注销之后
+//
+// @param fn
+//
+func BeforeStop(fn func() error) Option {
+	return func(o *Options) {
+		o.BeforeStop = append(o.BeforeStop, fn)
+	}
+}
+
+// 服务停止后调用
+//
+// @param fn
+//
+func AfterStop(fn func() error) Option {
+	return func(o *Options) {
+		o.AfterStop = append(o.AfterStop, fn)
+	}
+}
+
+// 设置限流
+//
+// @param rate 		每秒处理的请求数
+// @param capacity 	桶的容量
+//
+func RateLimit(rate float64, capacity int64) Option {
+	return func(o *Options) {
+		o.Rate = rate
+		o.Capacity = capacity
+	}
+}
+
+// 设置熔断的超时时间
+//
+// @param timeout
+//
+func Timeout(timeout time.Duration) Option {
+	return func(o *Options) {
+		o.Timeout = timeout
+	}
+}
+
+// 设置注册的节点过期时间，进程异常退出时节点会在ttl后从s2s中删除
+//
+// @param ttl
+//
+func RegisterTTL(ttl time.Duration) Option {
+	return func(o *Options) {
+		o.RegisterTTL = ttl
+	}
+}
+
+// 设置重新注册的间隔，需要小于节点过期时间
+//
+// @param interval
+//
+func RegisterInterval(interval time.Duration) Option {
+	return func(o *Options) {
+		o.RegisterInterval = interval
+	}
+}
+
+// 添加其他go-micro选项
+//
+// @param opts
+//
+func MicroOptions(opts ...micro.Option) Option {
+	return func(o *Options) {
+		o.MicroOptions = append(o.MicroOptions, opts...)
+	}
+}
+
+// 创建服务对象，没有设置的选项使用配置中的值
+//
+// @param opts
+// @return micro.Service
+//
+func New(opts ...Option) micro.Service {
+	options := Options{
+		Metrics: true,
+	}
+	for _, o := range opts {
+		o(&options)
+	}
+
+	// 每个服务使用自己的配置来源
+	p := options.Config
+	if nil == p {
+		p = conf.Default()
+	}
+
+	name := confString(p, "", "name")
+	version := confString(p, "0.0.1", "version")
+
+	svr_name = name
+	if 0 >= options.Timeout {
+		options.Timeout = time.Duration(p.Get("timeout").Int(3)) * time.Second
+	}
+	hystrixsrc.DefaultTimeout = int(options.Timeout / time.Millisecond)
+
+	// 设置限流，设置能同时处理的请求数，超过这个数就不继续处理
+	if 0 >= options.Rate {
+		options.Rate = p.Get("rate").Float64(1000)
+	}
+	if 0 >= options.Capacity {
+		options.Capacity = p.Get("rate").Int64(1000) + 200
+	}
+	br := ratelimit.NewBucketWithRate(options.Rate, options.Capacity)
+
+	if nil == options.Registry {
+		options.Registry = newRegistry(p)
+	}
+
+	mopts := make([]micro.Option, 0)
+	if nil != options.Router {
+		srv := httpServer.NewServer(
+			server.Name(name),
+			server.Version(version),
+		)
+
+		hd := srv.NewHandler(options.Router)
+		err := srv.Handle(hd)
+		if nil != err {
+			panic(err)
+		}
+
+		mopts = append(mopts, micro.Server(srv))
+	} else {
+		if nil == options.Transport {
+			options.Transport = grpc.NewTransport()
+		}
+
+		mopts = append(mopts,
+			micro.Name(name),
+			micro.Transport(options.Transport),
+			micro.Version(version),
+		)
+	}
+
+	// 注册的节点ttl秒后过期，每interval秒重新注册一次
+	// 没有设置时不修改go-micro的默认值
+	if 0 >= options.RegisterTTL {
+		options.RegisterTTL = time.Duration(p.Get("s2s", "register_ttl").Int(0)) * time.Second
+	}
+	if 0 >= options.RegisterInterval {
+		options.RegisterInterval = time.Duration(p.Get("s2s", "register_interval").Int(0)) * time.Second
+	}
+	if 0 < options.RegisterTTL {
+		mopts = append(mopts, micro.RegisterTTL(options.RegisterTTL))
+	}
+	if 0 < options.RegisterInterval {
+		mopts = append(mopts, micro.RegisterInterval(options.RegisterInterval))
+	}
+
+	mopts = append(mopts,
+		micro.Registry(options.Registry),
+
+		// 设置熔断,超过默认值就直接不发送请求
+		// 可以通过 github.com/afex/hystrix-go/hystrix设置默认值
+		// 超时时间和并发数
+		// 所有从此节点发出的Micro服务调用都会受到熔断插件的限制和保护。
+		// 熔断是调用级别的
+		// doc:https://medium.com/@dche423/micro-in-action-7-cn-ce75d5847ef4
+		// 熔断功能作用于客户端，设置恰当阈值以后， 它可以保障客户端资源不会被耗尽
+		// —— 哪怕是它所依赖的服务处于不健康的状态，也会快速返回错误，而不是让调用方长时间等待。
+		micro.WrapClient(hystrix.NewClientWrapper()),
+		// 用于限流限频
+		// 与熔断类似， 限流也是分布式系统中常用的功能。
+		// 不同的是， 限流在服务端生效，它的作用是保护服务器： 在请求处理速度达到设定的限制以后，
+		// 便不再接收和处理更多新请求，直到原有请求处理完成， 腾出空闲。 避免服务器因为客户端的疯狂调用而整体垮掉。
+		micro.WrapClient(ratelimiter.NewClientWrapper(br, false)),
+		micro.WrapHandler(ratelimiter.NewHandlerWrapper(br, false)),
+	)
+
+	if options.Metrics {
+		// 调用数据在后台批量上报，服务停止后发送剩余的数据
+		acquireFoot(p)
+
+		mopts = append(mopts,
+			// 客户端调用跟踪，每个请求调用之前都会调用这个中间件函数
+			micro.WrapCall(metricsWrap),
+			// 服务端被调跟踪，每个请求被处理之前都会调用这个中间件函数
+			micro.WrapHandler(logWrapper),
+			micro.AfterStop(releaseFoot),
+		)
+	}
+
+	for _, w := range options.ClientWrappers {
+		mopts = append(mopts, micro.WrapClient(w))
+	}
+	for _, w := range options.CallWrappers {
+		mopts = append(mopts, micro.WrapCall(w))
+	}
+	for _, w := range options.HandlerWrappers {
+		mopts = append(mopts, micro.WrapHandler(w))
+	}
+
+	// 先下线和注销节点，再执行其他的清理
+	mopts = append(mopts, micro.BeforeStop(func() error {
+		return beforeStop(p)
+	}))
+	for _, fn := range options.BeforeStart {
+		mopts = append(mopts, micro.BeforeStart(fn))
+	}
+	for _, fn := range options.AfterStart {
+		mopts = append(mopts, micro.AfterStart(fn))
+	}
+	for _, fn := range options.BeforeStop {
+		mopts = append(mopts, micro.BeforeStop(fn))
+	}
+	for _, fn := range options.AfterStop {
+		mopts = append(mopts, micro.AfterStop(fn))
+	}
+
+	mopts = append(mopts, options.MicroOptions...)
+
+	svr := micro.NewService(mopts...)
+	svr.Init()
+	gcGo()
+	return svr
+}
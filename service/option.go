@@ -4,9 +4,12 @@ import (
 	"errors"
 	"io/ioutil"
 	"os"
+	"sync"
+	"time"
 
 	goyaml "gopkg.in/yaml.v2"
 
+	"github.com/heegspace/heegrpc/conf"
 	s2s "github.com/heegspace/heegrpc/registry"
 	"github.com/micro/go-micro/v2/config"
 	"github.com/micro/go-micro/v2/config/encoder/yaml"
@@ -26,7 +29,8 @@ func fileExist(path string) bool {
 }
 
 // 加载配置，配置格式必须是yaml
-// 如果conf不等于nil,则将其解码到conf结构中
+// 如果conf不等于nil,则将其解码到conf结构中，文件修改后自动重新解析
+// conf只在加载时填充，重新解析后的配置通过ConfValue或者ConfNotify获取
 //
 // @param   conffile    配置文件
 // @param   conf        解析引入结构
@@ -49,6 +53,11 @@ func LoadConf(conffile string, conf interface{}) (err error) {
 		return
 	}
 
+	// 先记录修改时间，读取之后的修改会被重新加载
+	info, err := os.Stat(conffile)
+	if nil != err {
+		return
+	}
 	yamldata, err := ioutil.ReadFile(conffile)
 	if nil != err {
 		return
@@ -60,29 +69,101 @@ func LoadConf(conffile string, conf interface{}) (err error) {
 
 	// 获取服务中使用的nodes列表
 	// 主要用于定时获取s2s信息
-	mp := config.Map()
-	watchnodes := make([]string, 0)
-	if _, ok := mp["nodes"]; ok {
-		switch mp["nodes"].(type) {
-		case map[string]interface{}:
-			nodes := mp["nodes"].(map[string]interface{})
-			for _, v := range nodes {
-				watchnodes = append(watchnodes, v.(string))
-			}
-
-			break
-
-		default:
-			break
+	watchnodes := confNodes(yamldata)
+	s2s.SetWatchNode(watchnodes)
+
+	// 文件修改后重新解析，并同步nodes列表
+	watchConf(conffile, conf, info.ModTime(), watchnodes)
+	return
+}
+
+const (
+	// 检查apollo配置变化的间隔，heegapo在本地缓存了配置，读取不需要请求apollo
+	apolloInterval = time.Second
+)
+
+var (
+	apolloLock      sync.Mutex
+	apolloBindings  = make(map[string]*conf.Binding)
+	apolloCallbacks = make(map[string][]conf.ChangeFunc)
+)
+
+// 加载apollo中namespace的配置，解析到conf结构中，配置通过heegapo读取
+// c是加载时的快照，之后不会被修改，配置变化后通过ApolloValue获取最新的配置，并调用OnApolloChange注册的回调
+//
+// @param namespace 	apollo的namespace，字段的路径和heegapo.Config中的keys相同
+// @param c 			结构指针
+//
+func ApolloConf(namespace string, c interface{}) (err error) {
+	return ApolloConfWith(conf.NewApollo(namespace), namespace, c, apolloInterval)
+}
+
+// 从指定的配置来源加载namespace的配置，测试中可以使用内存配置或者模拟的apollo
+// 其他和ApolloConf相同，ApolloValue和OnApolloChange使用同一个namespace
+//
+// @param p 			配置来源
+// @param namespace
+// @param c 			结构指针，加载时的快照
+// @param interval 	检查配置变化的间隔，小于等于0时为1秒
+//
+func ApolloConfWith(p conf.Provider, namespace string, c interface{}, interval time.Duration) (err error) {
+	if 0 >= interval {
+		interval = apolloInterval
+	}
+
+	b, err := conf.NewBinding(p, c, interval)
+	if nil != err {
+		return
+	}
+
+	b.OnChange(func(old, new interface{}) {
+		apolloLock.Lock()
+		callbacks := make([]conf.ChangeFunc, len(apolloCallbacks[namespace]))
+		copy(callbacks, apolloCallbacks[namespace])
+		apolloLock.Unlock()
+
+		for _, fn := range callbacks {
+			fn(old, new)
 		}
+	})
+
+	// 重复加载时使用新的结构
+	apolloLock.Lock()
+	if prev, ok := apolloBindings[namespace]; ok {
+		prev.Stop()
 	}
+	apolloBindings[namespace] = b
+	apolloLock.Unlock()
 
-	s2s.SetWatchNode(watchnodes)
 	return
 }
 
-// https://github.com/shockerjue/apollo
-func ApolloConf(namespace string, conf interface{}) (err error) {
+// namespace最新的配置，类型和ApolloConf传入的指针相同，没有加载时返回nil
+// 返回的结构不能修改
+//
+// @param namespace
+// @return interface{}
+//
+func ApolloValue(namespace string) interface{} {
+	apolloLock.Lock()
+	b, ok := apolloBindings[namespace]
+	apolloLock.Unlock()
+	if !ok {
+		return nil
+	}
 
-	return
+	return b.Load()
+}
+
+// 注册apollo配置变化的回调，old和new是ApolloConf传入结构的副本
+// 可以在ApolloConf之前注册
+//
+// @param namespace
+// @param fn
+//
+func OnApolloChange(namespace string, fn func(old, new interface{})) {
+	apolloLock.Lock()
+	defer apolloLock.Unlock()
+
+	apolloCallbacks[namespace] = append(apolloCallbacks[namespace], fn)
 }
@@ -0,0 +1,288 @@
+package service
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	goyaml "gopkg.in/yaml.v2"
+
+	s2s "github.com/heegspace/heegrpc/registry"
+	"github.com/micro/go-micro/v2/config"
+	"go-micro.dev/v4/logger"
+)
+
+// LoadConf加载的配置文件，修改时间变化后重新解析
+type confFile struct {
+	path string
+
+	// 最新的配置，类型和LoadConf传入的指针相同，每次重新加载都发布新的副本
+	value atomic.Value
+
+	lock sync.Mutex
+	// LoadConf传入的结构类型
+	typ reflect.Type
+	mod time.Time
+	// 文件中的nodes，已经加入s2s的订阅
+	nodes     []string
+	callbacks []func(old, new interface{})
+	// ConfNotify返回的通道
+	notifies []chan interface{}
+	// 已经开始检查文件变化
+	running bool
+}
+
+var (
+	confFiles = make(map[string]*confFile)
+	confLock  sync.Mutex
+)
+
+func getConfFile(path string) *confFile {
+	confLock.Lock()
+	defer confLock.Unlock()
+
+	f, ok := confFiles[path]
+	if !ok {
+		f = &confFile{path: path}
+		confFiles[path] = f
+	}
+
+	return f
+}
+
+// 配置文件中nodes的服务名，去重后排序
+//
+//	nodes:
+//	  user: user.svr
+//
+// @param data
+// @return []string
+//
+func confNodes(data []byte) []string {
+	mp := make(map[string]interface{})
+	err := goyaml.Unmarshal(data, &mp)
+	if nil != err {
+		return []string{}
+	}
+
+	set := make(map[string]bool)
+	switch nodes := mp["nodes"].(type) {
+	case map[string]interface{}:
+		for _, v := range nodes {
+			if name, ok := v.(string); ok {
+				set[name] = true
+			}
+		}
+
+	case map[interface{}]interface{}:
+		for _, v := range nodes {
+			if name, ok := v.(string); ok {
+				set[name] = true
+			}
+		}
+	}
+
+	watchnodes := make([]string, 0, len(set))
+	for name := range set {
+		watchnodes = append(watchnodes, name)
+	}
+	sort.Strings(watchnodes)
+
+	return watchnodes
+}
+
+// 比较两次的nodes
+//
+// @param old
+// @param nodes
+// @return {added,removed}
+//
+func diffNodes(old, nodes []string) ([]string, []string) {
+	exist := make(map[string]bool, len(old))
+	for _, v := range old {
+		exist[v] = true
+	}
+
+	added := make([]string, 0)
+	for _, v := range nodes {
+		if exist[v] {
+			delete(exist, v)
+
+			continue
+		}
+
+		added = append(added, v)
+	}
+
+	removed := make([]string, 0, len(exist))
+	for v := range exist {
+		removed = append(removed, v)
+	}
+	sort.Strings(removed)
+
+	return added, removed
+}
+
+// 开始监听配置文件，同一个文件再次加载时使用新的结构
+// 检查间隔读取配置中的reload_interval，单位秒，默认5，小于等于0不重新加载
+//
+// @param path
+// @param conf 		只保存副本，之后不会再修改
+// @param mod 		已经解析的文件的修改时间
+// @param nodes 	已经订阅的nodes
+//
+func watchConf(path string, conf interface{}, mod time.Time, nodes []string) {
+	if reflect.Ptr != reflect.TypeOf(conf).Kind() {
+		return
+	}
+
+	f := getConfFile(path)
+	interval := config.Get("reload_interval").Int(5)
+
+	// 保存一份副本，调用方修改conf不会影响发布的配置
+	typ := reflect.TypeOf(conf).Elem()
+	cur := reflect.New(typ)
+	cur.Elem().Set(reflect.ValueOf(conf).Elem())
+
+	f.lock.Lock()
+	old := f.nodes
+	f.typ = typ
+	f.value.Store(cur.Interface())
+	f.mod = mod
+	f.nodes = nodes
+	start := !f.running && 0 < interval
+	if start {
+		f.running = true
+	}
+	f.lock.Unlock()
+
+	// 再次加载时SetWatchNode已经重新订阅，取消上一次的订阅
+	s2s.Unsubscribe(old...)
+
+	if start {
+		go f.run(time.Duration(interval) * time.Second)
+	}
+}
+
+// 注册配置文件重新加载后的回调，old和new是LoadConf传入结构的副本
+//
+// @param conffile 	LoadConf使用的文件
+// @param fn
+//
+func OnConfChange(conffile string, fn func(old, new interface{})) {
+	f := getConfFile(conffile)
+	f.lock.Lock()
+	f.callbacks = append(f.callbacks, fn)
+	f.lock.Unlock()
+}
+
+// 配置文件最新的配置，类型和LoadConf传入的指针相同，没有加载时返回nil
+// 返回的结构不能修改
+//
+// @param conffile
+// @return interface{}
+//
+func ConfValue(conffile string) interface{} {
+	return getConfFile(conffile).value.Load()
+}
+
+// 配置文件重新加载的通知，每次重新加载后发送新的配置
+// 通道中只保留最新的一个，没有及时读取时旧的配置被丢弃
+//
+// @param conffile
+// @return <-chan interface{}
+//
+func ConfNotify(conffile string) <-chan interface{} {
+	ch := make(chan interface{}, 1)
+
+	f := getConfFile(conffile)
+	f.lock.Lock()
+	f.notifies = append(f.notifies, ch)
+	f.lock.Unlock()
+
+	return ch
+}
+
+func (f *confFile) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		f.reload()
+	}
+}
+
+// 文件修改后重新解析到新的结构再发布，解析失败时保留原来的配置
+//
+func (f *confFile) reload() {
+	info, err := os.Stat(f.path)
+	if nil != err {
+		logger.Warnf("[conf] stat %s err: %v", f.path, err)
+
+		return
+	}
+
+	f.lock.Lock()
+	if info.ModTime().Equal(f.mod) || nil == f.typ {
+		f.lock.Unlock()
+
+		return
+	}
+	// 解析失败时等文件再次修改后重试
+	f.mod = info.ModTime()
+	typ := f.typ
+	f.lock.Unlock()
+
+	data, err := ioutil.ReadFile(f.path)
+	if nil != err {
+		logger.Errorf("[conf] read %s err: %v", f.path, err)
+
+		return
+	}
+
+	next := reflect.New(typ)
+	err = goyaml.Unmarshal(data, next.Interface())
+	if nil != err {
+		logger.Errorf("[conf] parse %s err, keep last config: %v", f.path, err)
+
+		return
+	}
+	nodes := confNodes(data)
+
+	f.lock.Lock()
+	// 再次LoadConf时可能换了结构
+	if typ != f.typ {
+		f.lock.Unlock()
+
+		return
+	}
+	old := f.value.Load()
+	f.value.Store(next.Interface())
+
+	added, removed := diffNodes(f.nodes, nodes)
+	f.nodes = nodes
+
+	callbacks := make([]func(old, new interface{}), len(f.callbacks))
+	copy(callbacks, f.callbacks)
+	for _, ch := range f.notifies {
+		// 丢弃没有读取的旧配置
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- next.Interface()
+	}
+	f.lock.Unlock()
+
+	s2s.Subscribe(added...)
+	s2s.Unsubscribe(removed...)
+	logger.Infof("[conf] reload %s, added nodes: %v, removed nodes: %v", f.path, added, removed)
+
+	for _, fn := range callbacks {
+		fn(old, next.Interface())
+	}
+}
@@ -8,14 +8,13 @@ import (
 	"reflect"
 	"runtime"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
-	hystrixsrc "github.com/afex/hystrix-go/hystrix"
 	"github.com/gin-gonic/gin"
 
 	"github.com/StabbyCutyou/buffstreams"
-	"github.com/asim/go-micro/plugins/wrapper/breaker/hystrix/v4"
-	"github.com/juju/ratelimit"
 	"github.com/micro/go-micro/v2/config"
 	"go-micro.dev/v4"
 	"go-micro.dev/v4/client"
@@ -25,11 +24,8 @@ import (
 	"go-micro.dev/v4/server"
 
 	httpClient "github.com/asim/go-micro/plugins/client/http/v4"
-	httpServer "github.com/asim/go-micro/plugins/server/http/v4"
-	grpc "github.com/asim/go-micro/plugins/transport/grpc/v4"
-	ratelimiter "github.com/asim/go-micro/plugins/wrapper/ratelimiter/ratelimit/v4"
-	"github.com/heegspace/heegapo"
 	foot "github.com/heegspace/heegrpc/callfoot"
+	"github.com/heegspace/heegrpc/conf"
 	console "github.com/heegspace/heegrpc/console"
 	s2s "github.com/heegspace/heegrpc/registry"
 	registry "go-micro.dev/v4/registry"
@@ -45,6 +41,24 @@ func errstr(err error) string {
 	return err.Error()
 }
 
+// 服务发现时默认使用的节点过滤条件
+// s2s.zone设置后只选择同一个机房的节点，没有zone的节点也会保留
+// 单次调用可以使用client.WithSelectOption(selector.WithFilter(s2s.SelectorFilter(...)))
+//
+// @param p 	配置来源
+// @return {[]Filter}
+//
+func registryFilters(p conf.Provider) []s2s.Filter {
+	filters := make([]s2s.Filter, 0)
+
+	zone := p.Get("s2s", "zone").String("")
+	if 0 < len(zone) {
+		filters = append(filters, s2s.Zone(zone))
+	}
+
+	return filters
+}
+
 type response struct {
 	rescode interface{}
 	resmsg  interface{}
@@ -124,11 +138,9 @@ func metricsWrap(cf client.CallFunc) client.CallFunc {
 			}
 		}
 
-		// 上报数据到统计服务
-		var fres foot.RPCFootRes
-		ferr := HttpRequest(heegapo.DefaultApollo.Config("heegspace.common.yaml", "statis", "svrname").String("footnode"),
-			heegapo.DefaultApollo.Config("heegspace.common.yaml", "statis", "rpcmethod").String("/foot/rpc"), freq, &fres, "application/proto")
-		logger.Infof("[Metrics Wrapper]-%v, Req: %v, Res: %s ,err: %v, footerr: %v, duration: %v\n", req.Method(), req.Body(), res, err, ferr, time.Since(t))
+		// 上报数据到统计服务，异步批量发送
+		reportFoot(freq)
+		logger.Infof("[Metrics Wrapper]-%v, Req: %v, Res: %s ,err: %v, duration: %v\n", req.Method(), req.Body(), res, err, time.Since(t))
 		return err
 	}
 }
@@ -173,15 +185,60 @@ func logWrapper(fn server.HandlerFunc) server.HandlerFunc {
 			}
 		}
 
-		// 上报数据到统计服务
-		var fres foot.RPCFootRes
-		ferr := HttpRequest(heegapo.DefaultApollo.Config("heegspace.common.yaml", "statis", "svrname").String("footnode"),
-			heegapo.DefaultApollo.Config("heegspace.common.yaml", "statis", "rpcmethod").String("/foot/rpc"), freq, &fres, "application/proto")
-		logger.Infof("[Log Wrapper]-%v, Req: %v, Res: %s, from: %v, ip: %v, errinfo: %v, ferrinfo: %v, duration: %v\n", req.Method(), req.Body(), res, md["Remote"], md["Local"], err, ferr, time.Since(t))
+		// 上报数据到统计服务，异步批量发送
+		reportFoot(freq)
+		logger.Infof("[Log Wrapper]-%v, Req: %v, Res: %s, from: %v, ip: %v, errinfo: %v, duration: %v\n", req.Method(), req.Body(), res, md["Remote"], md["Local"], err, time.Since(t))
 		return err
 	}
 }
 
+var (
+	// 按文件缓存的静态注册中心，每个注册中心都有检查文件变化的协程
+	staticRegistries = make(map[string]registry.Registry)
+	staticLock       sync.Mutex
+)
+
+// 同一个文件只创建一次静态注册中心
+//
+// @param p
+// @param path
+// @return registry.Registry
+//
+func staticRegistry(p conf.Provider, path string) registry.Registry {
+	staticLock.Lock()
+	defer staticLock.Unlock()
+
+	r, ok := staticRegistries[path]
+	if !ok {
+		r = s2s.NewStaticRegistry(
+			s2s.StaticFile(path),
+			s2s.Filters(registryFilters(p)...),
+		)
+		staticRegistries[path] = r
+	}
+
+	return r
+}
+
+// 创建注册中心，配置中registry为static时从registry_file读取服务节点，用于本地开发
+// 默认使用s2s
+//
+// @param p 	配置来源
+// @return registry.Registry
+//
+func newRegistry(p conf.Provider) registry.Registry {
+	if "static" == confString(p, "s2s", "registry") {
+		return staticRegistry(p, confString(p, "registry.yaml", "registry_file"))
+	}
+
+	return s2s.NewRegistry(
+		registry.Addrs(p.Get("s2s", "address").String("")),
+		registry.Secure(p.Get("s2s", "secure").Bool(false)),
+		s2s.Filters(registryFilters(p)...),
+		s2s.Config(p),
+	)
+}
+
 // 获取客户端对象
 //
 func NewClient() client.Client {
@@ -192,119 +249,34 @@ func NewClient() client.Client {
 // 获取服务对象
 //
 func NewService() micro.Service {
-	svr_name = config.Get("name").String("")
-	hystrixsrc.DefaultTimeout = heegapo.DefaultApollo.Config("heegspace.common.yaml", "timeout").Int(3) * 1000
-
-	// Create a new service. Optionally include some options here.
-	// 设置限流，设置能同时处理的请求数，超过这个数就不继续处理
-	br := ratelimit.NewBucketWithRate(heegapo.DefaultApollo.Config("heegspace.common.yaml", "rate").Float64(1000),
-		heegapo.DefaultApollo.Config("heegspace.common.yaml", "rate").Int64(1000)+200)
-
-	regis := s2s.NewRegistry(
-		registry.Addrs(heegapo.DefaultApollo.Config("heegspace.common.yaml", "s2s", "address").String("")),
-		registry.Secure(heegapo.DefaultApollo.Config("heegspace.common.yaml", "s2s", "secure").Bool()),
-	)
-	svr := micro.NewService(
-		micro.Name(config.Get("name").String("")),
-		micro.Transport(grpc.NewTransport()),
-		micro.Registry(regis),
-		micro.Version(config.Get("version").String("0.0.1")),
-
-		// 设置熔断,超过默认值就直接不发送请求
-		// 可以通过 github.com/afex/hystrix-go/hystrix设置默认值
-		// 超时时间和并发数
-		// 所有从此节点发出的Micro服务调用都会受到熔断插件的限制和保护。
-		// 熔断是调用级别的
-		// doc:https://medium.com/@dche423/micro-in-action-7-cn-ce75d5847ef4
-		// 熔断功能作用于客户端，设置恰当阈值以后， 它可以保障客户端资源不会被耗尽
-		// —— 哪怕是它所依赖的服务处于不健康的状态，也会快速返回错误，而不是让调用方长时间等待。
-		micro.WrapClient(hystrix.NewClientWrapper()),
-		// 用于限流限频
-		// 与熔断类似， 限流也是分布式系统中常用的功能。
-		// 不同的是， 限流在服务端生效，它的作用是保护服务器： 在请求处理速度达到设定的限制以后，
-		// 便不再接收和处理更多新请求，直到原有请求处理完成， 腾出空闲。 避免服务器因为客户端的疯狂调用而整体垮掉。
-		micro.WrapClient(ratelimiter.NewClientWrapper(br, false)),
-		micro.WrapHandler(ratelimiter.NewHandlerWrapper(br, false)),
-
-		// 客户端调用跟踪，每个请求调用之前都会调用这个中间件函数
-		micro.WrapCall(metricsWrap),
-		// 服务端被调跟踪，每个请求被处理之前都会调用这个中间件函数
-		micro.WrapHandler(logWrapper),
-		micro.BeforeStop(func() error {
-			if nil == s2s.GetDeregister().LocalSvr {
-				return nil
-			}
+	return New()
+}
 
-			regis.Deregister(s2s.GetDeregister().LocalSvr)
+// 服务停止前先下线节点，等待调用方更新节点后再注销
+// 等待时间读取配置中的s2s.drain_wait，默认1秒
+//
+// @param p 	服务的配置来源
+// @return error
+//
+func beforeStop(p conf.Provider) error {
+	if 0 == len(s2s.LocalServices()) {
+		return nil
+	}
 
-			// 等待3秒结束
-			logger.Info("Waitting 1 seconed over!")
-			time.Sleep(1 * time.Second)
+	s2s.Drain("")
 
-			return nil
-		}),
-	)
+	wait := p.Get("s2s", "drain_wait").Int(1)
+	logger.Info("Draining, waitting " + strconv.Itoa(wait) + " seconds over!")
+	time.Sleep(time.Duration(wait) * time.Second)
 
-	svr.Init()
-	gcGo()
-	return svr
+	// 注销本进程注册的所有服务
+	return s2s.Shutdown()
 }
 
 // 获取没有上报metrics的服务对象
 //
 func NewServiceNoMetrics() micro.Service {
-	svr_name = config.Get("name").String("")
-	hystrixsrc.DefaultTimeout = heegapo.DefaultApollo.Config("heegspace.common.yaml", "timeout").Int(3) * 1000
-
-	// Create a new service. Optionally include some options here.
-	// 设置限流，设置能同时处理的请求数，超过这个数就不继续处理
-	br := ratelimit.NewBucketWithRate(heegapo.DefaultApollo.Config("heegspace.common.yaml", "rate").Float64(1000),
-		heegapo.DefaultApollo.Config("heegspace.common.yaml", "rate").Int64(1000)+200)
-
-	regis := s2s.NewRegistry(
-		registry.Addrs(heegapo.DefaultApollo.Config("heegspace.common.yaml", "s2s", "address").String("")),
-		registry.Secure(heegapo.DefaultApollo.Config("heegspace.common.yaml", "s2s", "secure").Bool()),
-	)
-	svr := micro.NewService(
-		micro.Name(config.Get("name").String("")),
-		micro.Transport(grpc.NewTransport()),
-		micro.Registry(regis),
-		micro.Version(config.Get("version").String("0.0.1")),
-
-		// 设置熔断,超过默认值就直接不发送请求
-		// 可以通过 github.com/afex/hystrix-go/hystrix设置默认值
-		// 超时时间和并发数
-		// 所有从此节点发出的Micro服务调用都会受到熔断插件的限制和保护。
-		// 熔断是调用级别的
-		// doc:https://medium.com/@dche423/micro-in-action-7-cn-ce75d5847ef4
-		// 熔断功能作用于客户端，设置恰当阈值以后， 它可以保障客户端资源不会被耗尽
-		// —— 哪怕是它所依赖的服务处于不健康的状态，也会快速返回错误，而不是让调用方长时间等待。
-		micro.WrapClient(hystrix.NewClientWrapper()),
-		// 用于限流限频
-		// 与熔断类似， 限流也是分布式系统中常用的功能。
-		// 不同的是， 限流在服务端生效，它的作用是保护服务器： 在请求处理速度达到设定的限制以后，
-		// 便不再接收和处理更多新请求，直到原有请求处理完成， 腾出空闲。 避免服务器因为客户端的疯狂调用而整体垮掉。
-		micro.WrapClient(ratelimiter.NewClientWrapper(br, false)),
-		micro.WrapHandler(ratelimiter.NewHandlerWrapper(br, false)),
-
-		micro.BeforeStop(func() error {
-			if nil == s2s.GetDeregister().LocalSvr {
-				return nil
-			}
-
-			regis.Deregister(s2s.GetDeregister().LocalSvr)
-
-			// 等待3秒结束
-			logger.Info("Waitting 1 seconed over!")
-			time.Sleep(1 * time.Second)
-
-			return nil
-		}),
-	)
-
-	svr.Init()
-	gcGo()
-	return svr
+	return New(Metrics(false))
 }
 
 // 获取http服务对象
@@ -312,68 +284,7 @@ func NewServiceNoMetrics() micro.Service {
 // @return micro.Service
 //
 func HttpService(router *gin.Engine) micro.Service {
-	svr_name = config.Get("name").String("")
-	hystrixsrc.DefaultTimeout = heegapo.DefaultApollo.Config("heegspace.common.yaml", "timeout").Int(3) * 1000
-
-	// Create a new service. Optionally include some options here.
-	// 设置限流，设置能同时处理的请求数，超过这个数就不继续处理
-	br := ratelimit.NewBucketWithRate(heegapo.DefaultApollo.Config("heegspace.common.yaml", "rate").Float64(1000),
-		heegapo.DefaultApollo.Config("heegspace.common.yaml", "rate").Int64(1000)+200)
-
-	srv := httpServer.NewServer(
-		server.Name(config.Get("name").String("")),
-		server.Version(config.Get("version").String("0.0.1")),
-	)
-
-	hd := srv.NewHandler(router)
-	err := srv.Handle(hd)
-	if nil != err {
-		panic(err)
-	}
-
-	regis := s2s.NewRegistry(
-		registry.Addrs(heegapo.DefaultApollo.Config("heegspace.common.yaml", "s2s", "address").String("")),
-		registry.Secure(heegapo.DefaultApollo.Config("heegspace.common.yaml", "s2s", "secure").Bool()),
-	)
-	svrice := micro.NewService(
-		micro.Server(srv),
-		micro.Registry(regis),
-		// 客户端调用跟踪，每个请求调用之前都会调用这个中间件函数
-		micro.WrapCall(metricsWrap),
-		// 服务端被调跟踪，每个请求被处理之前都会调用这个中间件函数
-		micro.WrapHandler(logWrapper),
-		// 设置熔断,超过默认值就直接不发送请求
-		// 可以通过 github.com/afex/hystrix-go/hystrix设置默认值
-		// 超时时间和并发数
-		// 所有从此节点发出的Micro服务调用都会受到熔断插件的限制和保护。
-		// 熔断是调用级别的
-		// doc:https://medium.com/@dche423/micro-in-action-7-cn-ce75d5847ef4
-		// 熔断功能作用于客户端，设置恰当阈值以后， 它可以保障客户端资源不会被耗尽
-		// —— 哪怕是它所依赖的服务处于不健康的状态，也会快速返回错误，而不是让调用方长时间等待。
-		micro.WrapClient(hystrix.NewClientWrapper()),
-		// 用于限流限频
-		// 与熔断类似， 限流也是分布式系统中常用的功能。
-		// 不同的是， 限流在服务端生效，它的作用是保护服务器： 在请求处理速度达到设定的限制以后，
-		// 便不再接收和处理更多新请求，直到原有请求处理完成， 腾出空闲。 避免服务器因为客户端的疯狂调用而整体垮掉。
-		micro.WrapClient(ratelimiter.NewClientWrapper(br, false)),
-		micro.WrapHandler(ratelimiter.NewHandlerWrapper(br, false)),
-		micro.BeforeStop(func() error {
-			if nil == s2s.GetDeregister().LocalSvr {
-				return nil
-			}
-
-			// 等待3秒结束
-			regis.Deregister(s2s.GetDeregister().LocalSvr)
-			logger.Info("Waitting 1 seconed over!")
-			time.Sleep(1 * time.Second)
-
-			return nil
-		}),
-	)
-
-	svrice.Init()
-	gcGo()
-	return svrice
+	return New(Router(router))
 }
 
 // 获取http服务中对数据的编码器
@@ -399,15 +310,21 @@ func HttpCodec(contentType string) (codec Codec, err error) {
 	return
 }
 
-// 获取http客户端对象
+// 获取http客户端对象，使用默认的配置来源
 //
 // @return Client
 //
 func HttpClient() client.Client {
-	regis := s2s.NewRegistry(
-		registry.Addrs(heegapo.DefaultApollo.Config("heegspace.common.yaml", "s2s", "address").String("")),
-		registry.Secure(heegapo.DefaultApollo.Config("heegspace.common.yaml", "s2s", "secure").Bool()),
-	)
+	return HttpClientWith(conf.Default())
+}
+
+// 获取http客户端对象，注册中心从指定的配置来源读取
+//
+// @param p
+// @return Client
+//
+func HttpClientWith(p conf.Provider) client.Client {
+	regis := newRegistry(p)
 
 	s := selector.NewSelector(selector.Registry(regis))
 	httpcli := httpClient.NewClient(client.Selector(s))
@@ -463,6 +380,64 @@ func HttpRequest(svrname, method string, request, response interface{}, contentT
 	return
 }
 
+// 控制台内置命令的前缀，其他命令交给业务的回调处理
+const consolePrefix = "sys"
+
+// 处理控制台内置的命令，不是内置命令时ok为false
+// 内置命令都以sys开头，不会和业务的命令冲突
+// sys drain [name] 	节点下线，不再接收新的请求
+// sys undrain [name] 	节点恢复
+// sys state [name] 	查看节点状态
+// sys foot 			查看调用数据上报的统计
+//
+// @param cmd
+// @return {res,ok}
+//
+func consoleCmd(cmd string) (string, bool) {
+	fields := strings.Fields(strings.TrimRight(cmd, "\x00"))
+	if 0 == len(fields) || consolePrefix != fields[0] {
+		return "", false
+	}
+	fields = fields[1:]
+	if 0 == len(fields) {
+		return "usage: sys drain|undrain|state [name], sys foot\n", true
+	}
+
+	name := ""
+	if 1 < len(fields) {
+		name = fields[1]
+	}
+
+	var err error
+	switch fields[0] {
+	case "drain":
+		err = s2s.Drain(name)
+	case "undrain":
+		err = s2s.Undrain(name)
+	case "state":
+	case "foot":
+		stats := FootStats()
+
+		return fmt.Sprintf("queued %d sent %d failed %d dropped %d\n", stats.Queued, stats.Sent, stats.Failed, stats.Dropped), true
+	default:
+		return "unknown command " + fields[0] + "\n", true
+	}
+	if nil != err {
+		return err.Error() + "\n", true
+	}
+
+	res := ""
+	for _, svc := range s2s.LocalServices() {
+		if 0 < len(name) && name != svc.Name {
+			continue
+		}
+
+		res = res + svc.Name + " " + s2s.NodeState(svc.Name) + "\n"
+	}
+
+	return res, true
+}
+
 func Console(retCb console.RetCb) {
 	cfg := console.ConsoleListenerConfig{
 		MaxMessageSize: 1 << 20,
@@ -484,6 +459,13 @@ func Console(retCb console.RetCb) {
 			return nil
 		},
 		CmdCb: func(ctx context.Context, conn *net.TCPConn, cmd string) error {
+			// 内置的节点状态命令
+			if res, ok := consoleCmd(cmd); ok {
+				console.WriteToConsole(conn, []byte(res))
+
+				return nil
+			}
+
 			if nil != retCb {
 				res := retCb(cmd)
 				console.WriteToConsole(conn, []byte(res))
@@ -1,9 +1,203 @@
 package service
 
 import (
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/heegspace/heegrpc/conf"
+	"go-micro.dev/v4"
+	"go-micro.dev/v4/registry"
+	"go-micro.dev/v4/server"
 )
 
 func Test_NewService(t *testing.T) {
 	NewService()
 }
+
+func Test_ConfNodes(t *testing.T) {
+	nodes := confNodes([]byte("nodes:\n  user: user.svr\n  order: order.svr\n  user2: user.svr\n"))
+	if 2 != len(nodes) || "order.svr" != nodes[0] || "user.svr" != nodes[1] {
+		t.Fatalf("unexpected nodes %v", nodes)
+	}
+
+	added, removed := diffNodes(nodes, []string{"pay.svr", "user.svr"})
+	if 1 != len(added) || "pay.svr" != added[0] || 1 != len(removed) || "order.svr" != removed[0] {
+		t.Fatalf("unexpected diff %v %v", added, removed)
+	}
+}
+
+func Test_consoleCmd(t *testing.T) {
+	cases := []struct {
+		cmd string
+		ok  bool
+	}{
+		// 业务的命令交给回调处理
+		{"drain", false},
+		{"state user.svr", false},
+		{"foot", false},
+		{"", false},
+		{"sys", true},
+		{"sys foot", true},
+		{"sys foot\x00\x00", true},
+		{"sys unknown", true},
+	}
+
+	for _, c := range cases {
+		if _, ok := consoleCmd(c.cmd); ok != c.ok {
+			t.Errorf("%q: expect %v, got %v", c.cmd, c.ok, ok)
+		}
+	}
+
+	if res, _ := consoleCmd("sys foot"); !strings.HasPrefix(res, "queued ") {
+		t.Errorf("unexpected foot stats %q", res)
+	}
+}
+
+func Test_Options(t *testing.T) {
+	router := gin.New()
+	r := registry.NewMemoryRegistry()
+	p := conf.NewMemory(nil)
+	fn := func() error { return nil }
+
+	var o Options
+	for _, opt := range []Option{
+		Metrics(false),
+		Router(router),
+		Registry(r),
+		Config(p),
+		WrapClient(nil, nil),
+		WrapCall(nil),
+		WrapHandler(nil),
+		BeforeStart(fn),
+		AfterStart(fn),
+		BeforeStop(fn),
+		AfterStop(fn),
+		AfterStop(fn),
+		RateLimit(10, 20),
+		Timeout(time.Second),
+		RegisterTTL(30 * time.Second),
+		RegisterInterval(10 * time.Second),
+		MicroOptions(micro.Name("test.svr")),
+	} {
+		opt(&o)
+	}
+
+	if o.Metrics || router != o.Router || r != o.Registry || p != o.Config {
+		t.Fatalf("unexpected options %+v", o)
+	}
+	if 2 != len(o.ClientWrappers) || 1 != len(o.CallWrappers) || 1 != len(o.HandlerWrappers) {
+		t.Fatalf("unexpected wrappers %+v", o)
+	}
+	if 1 != len(o.BeforeStart) || 1 != len(o.AfterStart) || 1 != len(o.BeforeStop) || 2 != len(o.AfterStop) {
+		t.Fatalf("unexpected hooks %+v", o)
+	}
+	if 10 != o.Rate || 20 != o.Capacity || time.Second != o.Timeout {
+		t.Fatalf("unexpected limits %+v", o)
+	}
+	if 30*time.Second != o.RegisterTTL || 10*time.Second != o.RegisterInterval || 1 != len(o.MicroOptions) {
+		t.Fatalf("unexpected register options %+v", o)
+	}
+
+	// 选项传到go-micro，配置中的值不会覆盖选项
+	p.Set(90, "s2s", "register_ttl")
+	svr := New(Metrics(false), Registry(r), Config(p), RegisterTTL(30*time.Second), RegisterInterval(10*time.Second), MicroOptions(micro.Name("test.svr")))
+	so := svr.Options().Server.Options()
+	if 30*time.Second != so.RegisterTTL || 10*time.Second != so.RegisterInterval || "test.svr" != so.Name || r != so.Registry {
+		t.Fatalf("options not passed to go-micro %v %v %v", so.RegisterTTL, so.RegisterInterval, so.Name)
+	}
+}
+
+func Test_NewRegisterTTL(t *testing.T) {
+	// 都没有设置时保留go-micro的默认值，需要在其他设置ttl的用例之前检查
+	before := server.DefaultServer.Options()
+
+	cases := []struct {
+		name     string
+		opts     []Option
+		conf     map[string]interface{}
+		ttl      time.Duration
+		interval time.Duration
+	}{
+		{"default", nil, nil, before.RegisterTTL, before.RegisterInterval},
+		{"config", nil, map[string]interface{}{"register_ttl": 7, "register_interval": 3}, 7 * time.Second, 3 * time.Second},
+		{"option", []Option{RegisterTTL(5 * time.Second), RegisterInterval(2 * time.Second)}, nil, 5 * time.Second, 2 * time.Second},
+		{"option over config", []Option{RegisterTTL(6 * time.Second)}, map[string]interface{}{"register_ttl": 9, "register_interval": 4}, 6 * time.Second, 4 * time.Second},
+	}
+
+	for _, c := range cases {
+		p := conf.NewMemory(nil)
+		for k, v := range c.conf {
+			p.Set(v, "s2s", k)
+		}
+
+		opts := append([]Option{Metrics(false), Registry(registry.NewMemoryRegistry()), Config(p)}, c.opts...)
+		svr := New(opts...)
+
+		so := svr.Options().Server.Options()
+		if c.ttl != so.RegisterTTL || c.interval != so.RegisterInterval {
+			t.Errorf("%s: unexpected register ttl %v interval %v", c.name, so.RegisterTTL, so.RegisterInterval)
+		}
+	}
+}
+
+func Test_NewConfig(t *testing.T) {
+	def := conf.Default()
+
+	p := conf.NewMemory(nil)
+	p.Set("a.svr", "name")
+	p.Set("1.2.3", "version")
+	svr := New(Metrics(false), Registry(registry.NewMemoryRegistry()), Config(p))
+	if "a.svr" != svr.Server().Options().Name || "1.2.3" != svr.Server().Options().Version {
+		t.Fatalf("name not read from config %v %v", svr.Server().Options().Name, svr.Server().Options().Version)
+	}
+
+	// 每个服务使用自己的配置，不修改默认配置
+	q := conf.NewMemory(nil)
+	q.Set("b.svr", "name")
+	svr = New(Metrics(false), Registry(registry.NewMemoryRegistry()), Config(q))
+	if "b.svr" != svr.Server().Options().Name {
+		t.Fatalf("name not read from config %v", svr.Server().Options().Name)
+	}
+	if def != conf.Default() {
+		t.Fatal("default config replaced")
+	}
+}
+
+func Test_ApolloConfWith(t *testing.T) {
+	type appConf struct {
+		Limit int `yaml:"limit"`
+	}
+
+	p := conf.NewMemory(nil)
+	p.Set(10, "limit")
+
+	changed := make(chan [2]int, 1)
+	OnApolloChange("test.yaml", func(old, new interface{}) {
+		changed <- [2]int{old.(*appConf).Limit, new.(*appConf).Limit}
+	})
+
+	var c appConf
+	if err := ApolloConfWith(p, "test.yaml", &c, 10*time.Millisecond); nil != err {
+		t.Fatal(err)
+	}
+	if 10 != c.Limit || 10 != ApolloValue("test.yaml").(*appConf).Limit {
+		t.Fatalf("unexpected limit %d", c.Limit)
+	}
+
+	p.Set(20, "limit")
+	select {
+	case v := <-changed:
+		if 10 != v[0] || 20 != v[1] {
+			t.Fatalf("unexpected change %v", v)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("change not notified")
+	}
+
+	// c是加载时的快照
+	if 20 != ApolloValue("test.yaml").(*appConf).Limit || 10 != c.Limit {
+		t.Fatalf("unexpected limit %d %d", ApolloValue("test.yaml").(*appConf).Limit, c.Limit)
+	}
+}